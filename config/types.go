@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type ServerConfig struct {
 	ServerAddressPort string `env:"SERVER_ADDRESS_PORT" envDefault:"3001"`
 }
@@ -42,6 +44,12 @@ type NodeConfig struct {
 }
 
 type KubeConfig struct {
-	KubeConfigPath       string `env:"KUBE_CONFIG_PATH"`
-	ManualKubeConfigFlag bool   `env:"MANUAL_KUBE_CONFIG_FLAG"`
+	KubeConfigPath       string        `env:"KUBE_CONFIG_PATH"`
+	ManualKubeConfigFlag bool          `env:"MANUAL_KUBE_CONFIG_FLAG"`
+	IdentityNamespace    string        `env:"KUBE_IDENTITY_NAMESPACE" envDefault:"default"`
+	DeployTimeout        time.Duration `env:"KUBE_DEPLOY_TIMEOUT" envDefault:"15m"`
+	// PolicyAPICIDRs and PolicyAPINamespaces are admitted to the API and gateway
+	// ports of topology pods under NetworkPolicies; both empty = everyone.
+	PolicyAPICIDRs      []string `env:"KUBE_POLICY_API_CIDRS"`
+	PolicyAPINamespaces []string `env:"KUBE_POLICY_API_NAMESPACES"`
 }
@@ -11,14 +11,17 @@ import (
 
 	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 type App struct {
 	router              http.Handler
 	serverCfg           *config.ServerConfig
+	clusterCfg          *config.ClusterConfig
 	sqlDBCfg            *config.PostgreSqlConfig
 	sqlDBPool           *sql.DB
 	kubernetesCfg       *config.KubeConfig
+	kubernetesRestCfg   *rest.Config
 	kubernetesClient    *kubernetes.Clientset
 	kubernetesAPIClient *apiextension.Clientset
 }
@@ -34,6 +37,7 @@ func NewApp(cfg *config.Config) *App {
 	if err != nil {
 		log.Fatal(err)
 	}
+	go app.revertInterruptedChaos()
 
 	app.loadRoutes()
 
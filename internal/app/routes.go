@@ -17,7 +17,8 @@ func (a *App) loadRoutes() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Node-Id, X-Pod-Name, X-Ttfb-Ms, Server-Timing, Content-Disposition, Content-Range, Location")
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
 				return
@@ -31,17 +32,47 @@ func (a *App) loadRoutes() {
 			w.WriteHeader(http.StatusOK)
 		})
 
-		th := topologyhandlers.NewHandler(a.sqlDBPool, a.kubernetesClient)
+		th := topologyhandlers.NewHandler(a.sqlDBPool, a.kubernetesClient, a.kubernetesRestCfg, a.kubernetesCfg, a.clusterCfg)
 		r.Route("/topologies", func(r chi.Router) {
 			r.Get("/", th.GetAll)
 			r.Post("/", th.Create)
 			r.Get("/{topologyId}", th.GetByID)
 			r.Put("/{topologyId}", th.Update)
+			r.Get("/{topologyId}/settings", th.GetSettings)
+			r.Put("/{topologyId}/settings", th.UpdateSettings)
 			r.Delete("/{topologyId}", th.Delete)
+			r.Post("/{topologyId}/validate", th.Validate)
+			r.Post("/{topologyId}/plan", th.Plan)
+			r.Get("/{topologyId}/manifests", th.ExportManifests)
 			r.Post("/{topologyId}/deploy", th.Deploy)
 			r.Post("/{topologyId}/undeploy", th.Undeploy)
 			r.Get("/{topologyId}/status", th.GetStatus)
+			r.Get("/{topologyId}/status/stream", th.StreamStatus)
+			r.Get("/{topologyId}/events", th.GetEvents)
+			r.Get("/{topologyId}/cluster/peers", th.GetClusterPeers)
+			r.Get("/{topologyId}/edges/conformance", th.GetEdgeConformance)
+			r.Post("/{topologyId}/pins", th.AddPin)
+			r.Get("/{topologyId}/pins", th.ListPins)
+			r.Get("/{topologyId}/pins/{cid}", th.GetPin)
+			r.Delete("/{topologyId}/pins/{cid}", th.RemovePin)
+			r.Post("/{topologyId}/add", th.AddContent)
+			r.Get("/{topologyId}/content/{cid}/heatmap", th.GetContentHeatmap)
 			r.Get("/{topologyId}/pods/{podName}/logs", th.GetPodLogs)
+			r.Get("/{topologyId}/nodes/{nodeId}/logs", th.GetNodeLogs)
+			r.Post("/{topologyId}/nodes/{nodeId}/chaos", th.StartChaos)
+			r.Get("/{topologyId}/chaos", th.ListChaosExperiments)
+			r.Get("/{topologyId}/nodes/{nodeId}/ipfs/{cid}", th.FetchContent)
+			r.Head("/{topologyId}/nodes/{nodeId}/ipfs/{cid}", th.FetchContent)
+			r.Get("/{topologyId}/nodes/{nodeId}/ipfs/{cid}/*", th.FetchContent)
+			r.Head("/{topologyId}/nodes/{nodeId}/ipfs/{cid}/*", th.FetchContent)
+		})
+		r.Route("/deployments", func(r chi.Router) {
+			r.Get("/{deploymentId}", th.GetDeployment)
+			r.Post("/{deploymentId}/cancel", th.CancelDeployment)
+		})
+		r.Route("/chaos", func(r chi.Router) {
+			r.Get("/{experimentId}", th.GetChaosExperiment)
+			r.Post("/{experimentId}/stop", th.StopChaosExperiment)
 		})
 	})
 
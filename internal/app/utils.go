@@ -1,11 +1,13 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"ipfs-visualizer/config"
 	psql_connection "ipfs-visualizer/internal/db/psql/connection"
 	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
 	"ipfs-visualizer/internal/kube"
+	"ipfs-visualizer/internal/services/topology"
 	"log/slog"
 
 	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
@@ -13,6 +15,7 @@ import (
 
 func (a *App) loadGeneralCfg(cfg *config.Config) {
 	a.serverCfg = &cfg.ServerCfg
+	a.clusterCfg = &cfg.ClusterCfg
 }
 
 func (a *App) createStorageConnections(cfg *config.Config) error {
@@ -30,6 +33,10 @@ func (a *App) createStorageConnections(cfg *config.Config) error {
 
 func CreatePSQLTablesIfNotExist(sqlPool *sql.DB) {
 	topologymodels.CreateTopologyTablesIfNotExist(sqlPool)
+	// Deploy workers do not survive a restart.
+	if err := topologymodels.FailUnfinishedTopologyDeployments(context.Background(), sqlPool, "error", "interrupted by server restart"); err != nil {
+		slog.Error("FailUnfinishedTopologyDeployments", "error", err)
+	}
 }
 
 func (a *App) CloseStorageConnections() error {
@@ -62,8 +69,17 @@ func (a *App) CreateKubeClientSet() error {
 		return NewClientError("CreateKubeClientSet", "failed to create api extension clientset", err)
 	}
 
+	a.kubernetesRestCfg = kubeCfg
 	a.kubernetesClient = clientSet
 	a.kubernetesAPIClient = APIClientSet
 
 	return nil
 }
+
+// revertInterruptedChaos reverts the chaos experiments left running by the
+// previous server process; their workers do not survive a restart.
+func (a *App) revertInterruptedChaos() {
+	if err := topology.RevertInterruptedChaos(context.Background(), a.sqlDBPool, a.kubernetesClient, a.kubernetesRestCfg); err != nil {
+		slog.Error("RevertInterruptedChaos", "error", err)
+	}
+}
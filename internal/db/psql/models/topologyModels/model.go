@@ -1,16 +1,55 @@
 package topologymodels
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type TopologyModel struct {
 	TopologyID   string     `db:"topology_id" json:"topologyId"`
 	Name         string     `db:"name" json:"name"`
 	DeployStatus string     `db:"deploy_status" json:"deployStatus"`
 	K8sNamespace *string    `db:"k8s_namespace" json:"k8sNamespace,omitempty"`
+	Settings     TopologySettingsModel `db:"settings" json:"settings"`
 	CreatedAt    time.Time  `db:"created_at" json:"createdAt"`
 	UpdatedAt    time.Time  `db:"updated_at" json:"updatedAt"`
 }
 
+// TopologySettingsModel holds the deployment settings of a topology, stored as JSONB.
+type TopologySettingsModel struct {
+	IPFSImage          string                     `json:"ipfsImage,omitempty"`
+	ClusterImage       string                     `json:"clusterImage,omitempty"`
+	StorageClass       string                     `json:"storageClass,omitempty"`
+	IPFSStorageSize    string                     `json:"ipfsStorageSize,omitempty"`
+	ClusterStorageSize string                     `json:"clusterStorageSize,omitempty"`
+	ServiceType        string                     `json:"serviceType,omitempty"`
+	IPFSResources      *ContainerResourcesModel   `json:"ipfsResources,omitempty"`
+	ClusterResources   *ContainerResourcesModel   `json:"clusterResources,omitempty"`
+	IPFSProfiles       []string                   `json:"ipfsProfiles,omitempty"`
+	IPFSConfig         map[string]json.RawMessage `json:"ipfsConfig,omitempty"`
+	Cluster            *ClusterSettingsModel      `json:"cluster,omitempty"`
+	PrivateNetwork     bool                       `json:"privateNetwork,omitempty"`
+}
+
+// ClusterSettingsModel holds the ipfs-cluster service.json parameters of a topology.
+type ClusterSettingsModel struct {
+	Consensus            string   `json:"consensus,omitempty"`
+	ReplicationFactorMin int      `json:"replicationFactorMin,omitempty"`
+	ReplicationFactorMax int      `json:"replicationFactorMax,omitempty"`
+	TrustedPeers         []string `json:"trustedPeers,omitempty"`
+	MonitorPingInterval  string   `json:"monitorPingInterval,omitempty"`
+	MonitorCheckInterval string   `json:"monitorCheckInterval,omitempty"`
+	AllocateBy           []string `json:"allocateBy,omitempty"`
+}
+
+// ContainerResourcesModel holds CPU and memory requests and limits as quantities.
+type ContainerResourcesModel struct {
+	CPURequest    string `json:"cpuRequest,omitempty"`
+	CPULimit      string `json:"cpuLimit,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+}
+
 type TopologyNodeModel struct {
 	TopologyID string   `db:"topology_id" json:"topologyId"`
 	NodeID     string   `db:"node_id" json:"nodeId"`
@@ -18,6 +57,22 @@ type TopologyNodeModel struct {
 	PosX       float64  `db:"pos_x" json:"posX"`
 	PosY       float64  `db:"pos_y" json:"posY"`
 	Role       string   `db:"role" json:"role"`
+	Overrides  NodeOverridesModel `db:"overrides" json:"overrides"`
+}
+
+// NodeOverridesModel holds the per-node deploy overrides, stored as JSONB.
+type NodeOverridesModel struct {
+	IPFSImageTag       string                     `json:"ipfsImageTag,omitempty"`
+	ClusterImageTag    string                     `json:"clusterImageTag,omitempty"`
+	IPFSStorageSize    string                     `json:"ipfsStorageSize,omitempty"`
+	ClusterStorageSize string                     `json:"clusterStorageSize,omitempty"`
+	IPFSResources      *ContainerResourcesModel   `json:"ipfsResources,omitempty"`
+	ClusterResources   *ContainerResourcesModel   `json:"clusterResources,omitempty"`
+	Env                map[string]string          `json:"env,omitempty"`
+	Labels             map[string]string          `json:"labels,omitempty"`
+	Annotations        map[string]string          `json:"annotations,omitempty"`
+	IPFSProfiles       []string                   `json:"ipfsProfiles,omitempty"`
+	IPFSConfig         map[string]json.RawMessage `json:"ipfsConfig,omitempty"`
 }
 
 type TopologyEdgeModel struct {
@@ -27,6 +82,68 @@ type TopologyEdgeModel struct {
 	TargetNodeID  string `db:"target_node_id" json:"targetNodeId"`
 }
 
+// TopologyNodeIdentityModel holds the public part of a node's libp2p identities;
+// private keys are kept in a Kubernetes Secret.
+type TopologyNodeIdentityModel struct {
+	TopologyID string `db:"topology_id" json:"topologyId"`
+	NodeID     string `db:"node_id" json:"nodeId"`
+	PeerID     string `db:"peer_id" json:"peerId"`
+	IPFSPeerID string `db:"ipfs_peer_id" json:"ipfsPeerId"`
+}
+
+// TopologyGraph holds the rows written together with a topology row. A nil
+// list is left as it is; identities are replaced whenever the nodes are.
+type TopologyGraph struct {
+	Nodes      []TopologyNodeModel
+	Identities []TopologyNodeIdentityModel
+	Edges      []TopologyEdgeModel
+}
+
+// TopologyNodePodModel binds a canvas node to the pod it was deployed as.
+type TopologyNodePodModel struct {
+	TopologyID string `db:"topology_id" json:"topologyId"`
+	NodeID     string `db:"node_id" json:"nodeId"`
+	PodName    string `db:"pod_name" json:"podName"`
+	Workload   string `db:"workload" json:"workload"`
+	Ordinal    int    `db:"ordinal" json:"ordinal"`
+}
+
+// TopologyDeploymentModel is one deploy run of a topology with its step log.
+type TopologyDeploymentModel struct {
+	DeploymentID string                `db:"deployment_id" json:"deploymentId"`
+	TopologyID   string                `db:"topology_id" json:"topologyId"`
+	Namespace    string                `db:"namespace" json:"namespace"`
+	Status       string                `db:"status" json:"status"`
+	Message      string                `db:"message" json:"message"`
+	Steps        []DeploymentStepModel `db:"steps" json:"steps"`
+	CreatedAt    time.Time             `db:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time             `db:"updated_at" json:"updatedAt"`
+	FinishedAt   *time.Time            `db:"finished_at" json:"finishedAt,omitempty"`
+}
+
+// DeploymentStepModel is an entry of a deployment's step log, stored as JSONB.
+type DeploymentStepModel struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// TopologyChaosExperimentModel is one fault injected into the pod of a node and
+// reverted after its duration.
+type TopologyChaosExperimentModel struct {
+	ExperimentID    string     `db:"experiment_id" json:"experimentId"`
+	TopologyID      string     `db:"topology_id" json:"topologyId"`
+	NodeID          string     `db:"node_id" json:"nodeId"`
+	Namespace       string     `db:"namespace" json:"namespace"`
+	PodName         string     `db:"pod_name" json:"podName"`
+	Action          string     `db:"action" json:"action"`
+	DurationSeconds int        `db:"duration_seconds" json:"durationSeconds"`
+	Status          string     `db:"status" json:"status"`
+	Message         string     `db:"message" json:"message"`
+	StartedAt       time.Time  `db:"started_at" json:"startedAt"`
+	EndsAt          time.Time  `db:"ends_at" json:"endsAt"`
+	FinishedAt      *time.Time `db:"finished_at" json:"finishedAt,omitempty"`
+}
+
 type TopologySummaryRow struct {
 	TopologyID   string     `db:"topology_id"`
 	Name         string     `db:"name"`
@@ -7,9 +7,11 @@ const (
 			name VARCHAR(255) NOT NULL,
 			deploy_status VARCHAR(50) DEFAULT 'none',
 			k8s_namespace VARCHAR(255),
+			settings JSONB NOT NULL DEFAULT '{}',
 			created_at TIMESTAMP DEFAULT NOW(),
 			updated_at TIMESTAMP DEFAULT NOW()
-		);`
+		);
+		ALTER TABLE topologies ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';`
 
 	createTopologyNodesTable = `
 		CREATE TABLE IF NOT EXISTS topology_nodes (
@@ -19,8 +21,10 @@ const (
 			pos_x DOUBLE PRECISION NOT NULL,
 			pos_y DOUBLE PRECISION NOT NULL,
 			role VARCHAR(50) DEFAULT 'worker',
+			overrides JSONB NOT NULL DEFAULT '{}',
 			PRIMARY KEY (topology_id, node_id)
-		);`
+		);
+		ALTER TABLE topology_nodes ADD COLUMN IF NOT EXISTS overrides JSONB NOT NULL DEFAULT '{}';`
 
 	createTopologyEdgesTable = `
 		CREATE TABLE IF NOT EXISTS topology_edges (
@@ -31,6 +35,56 @@ const (
 			PRIMARY KEY (topology_id, edge_id)
 		);`
 
+	createTopologyNodePodsTable = `
+		CREATE TABLE IF NOT EXISTS topology_node_pods (
+			topology_id VARCHAR(255) NOT NULL REFERENCES topologies(topology_id) ON DELETE CASCADE,
+			node_id VARCHAR(255) NOT NULL,
+			pod_name VARCHAR(255) NOT NULL,
+			workload VARCHAR(255) NOT NULL,
+			ordinal INT NOT NULL,
+			PRIMARY KEY (topology_id, node_id)
+		);`
+
+	createTopologyNodeIdentitiesTable = `
+		CREATE TABLE IF NOT EXISTS topology_node_identities (
+			topology_id VARCHAR(255) NOT NULL REFERENCES topologies(topology_id) ON DELETE CASCADE,
+			node_id VARCHAR(255) NOT NULL,
+			peer_id VARCHAR(255) NOT NULL,
+			ipfs_peer_id VARCHAR(255) NOT NULL,
+			PRIMARY KEY (topology_id, node_id)
+		);`
+
+	createTopologyDeploymentsTable = `
+		CREATE TABLE IF NOT EXISTS topology_deployments (
+			deployment_id VARCHAR(255) PRIMARY KEY,
+			topology_id VARCHAR(255) NOT NULL REFERENCES topologies(topology_id) ON DELETE CASCADE,
+			namespace VARCHAR(255) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			steps JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			finished_at TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS topology_deployments_topology_idx ON topology_deployments (topology_id, created_at DESC);`
+
+	createTopologyChaosExperimentsTable = `
+		CREATE TABLE IF NOT EXISTS topology_chaos_experiments (
+			experiment_id VARCHAR(255) PRIMARY KEY,
+			topology_id VARCHAR(255) NOT NULL REFERENCES topologies(topology_id) ON DELETE CASCADE,
+			node_id VARCHAR(255) NOT NULL,
+			namespace VARCHAR(255) NOT NULL,
+			pod_name VARCHAR(255) NOT NULL,
+			action VARCHAR(50) NOT NULL,
+			duration_seconds INT NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			started_at TIMESTAMP DEFAULT NOW(),
+			ends_at TIMESTAMP NOT NULL,
+			finished_at TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS topology_chaos_experiments_topology_idx ON topology_chaos_experiments (topology_id, started_at DESC);`
+
 	getAllTopologiesQuery = `
 		SELECT t.topology_id, t.name, t.deploy_status, t.k8s_namespace, t.created_at,
 		       COALESCE((SELECT COUNT(*)::int FROM topology_nodes WHERE topology_id = t.topology_id), 0) AS node_count,
@@ -39,23 +93,23 @@ const (
 		ORDER BY t.updated_at DESC;`
 
 	getTopologyByIDQuery = `
-		SELECT topology_id, name, deploy_status, k8s_namespace, created_at, updated_at
+		SELECT topology_id, name, deploy_status, k8s_namespace, settings, created_at, updated_at
 		FROM topologies WHERE topology_id = $1;`
 
 	insertTopologyQuery = `
-		INSERT INTO topologies (topology_id, name, deploy_status, k8s_namespace)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO topologies (topology_id, name, deploy_status, k8s_namespace, settings)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING created_at, updated_at;`
 
 	updateTopologyQuery = `
-		UPDATE topologies SET name = $1, deploy_status = $2, k8s_namespace = $3, updated_at = NOW()
-		WHERE topology_id = $4
+		UPDATE topologies SET name = $1, deploy_status = $2, k8s_namespace = $3, settings = $4, updated_at = NOW()
+		WHERE topology_id = $5
 		RETURNING updated_at;`
 
 	deleteTopologyQuery = `DELETE FROM topologies WHERE topology_id = $1;`
 
 	getNodesByTopologyQuery = `
-		SELECT topology_id, node_id, label, pos_x, pos_y, role
+		SELECT topology_id, node_id, label, pos_x, pos_y, role, overrides
 		FROM topology_nodes WHERE topology_id = $1;`
 
 	getEdgesByTopologyQuery = `
@@ -63,15 +117,87 @@ const (
 		FROM topology_edges WHERE topology_id = $1;`
 
 	insertNodeQuery = `
-		INSERT INTO topology_nodes (topology_id, node_id, label, pos_x, pos_y, role)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (topology_id, node_id) DO UPDATE SET label = $3, pos_x = $4, pos_y = $5, role = $6;`
+		INSERT INTO topology_nodes (topology_id, node_id, label, pos_x, pos_y, role, overrides)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (topology_id, node_id) DO UPDATE SET label = $3, pos_x = $4, pos_y = $5, role = $6, overrides = $7;`
 
 	insertEdgeQuery = `
 		INSERT INTO topology_edges (topology_id, edge_id, source_node_id, target_node_id)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (topology_id, edge_id) DO UPDATE SET source_node_id = $3, target_node_id = $4;`
 
-	deleteNodesByTopologyQuery = `DELETE FROM topology_nodes WHERE topology_id = $1;`
-	deleteEdgesByTopologyQuery = `DELETE FROM topology_edges WHERE topology_id = $1;`
+	getNodeIdentitiesByTopologyQuery = `
+		SELECT topology_id, node_id, peer_id, ipfs_peer_id
+		FROM topology_node_identities WHERE topology_id = $1;`
+
+	insertNodeIdentityQuery = `
+		INSERT INTO topology_node_identities (topology_id, node_id, peer_id, ipfs_peer_id)
+		VALUES ($1, $2, $3, $4);`
+
+	getNodePodsByTopologyQuery = `
+		SELECT topology_id, node_id, pod_name, workload, ordinal
+		FROM topology_node_pods WHERE topology_id = $1
+		ORDER BY workload, ordinal;`
+
+	insertNodePodQuery = `
+		INSERT INTO topology_node_pods (topology_id, node_id, pod_name, workload, ordinal)
+		VALUES ($1, $2, $3, $4, $5);`
+
+	insertDeploymentQuery = `
+		INSERT INTO topology_deployments (deployment_id, topology_id, namespace, status, message, steps)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at;`
+
+	getDeploymentByIDQuery = `
+		SELECT deployment_id, topology_id, namespace, status, message, steps, created_at, updated_at, finished_at
+		FROM topology_deployments WHERE deployment_id = $1;`
+
+	getLatestDeploymentByTopologyQuery = `
+		SELECT deployment_id, topology_id, namespace, status, message, steps, created_at, updated_at, finished_at
+		FROM topology_deployments WHERE topology_id = $1
+		ORDER BY created_at DESC LIMIT 1;`
+
+	appendDeploymentStepQuery = `
+		UPDATE topology_deployments SET steps = steps || $2::jsonb, updated_at = NOW()
+		WHERE deployment_id = $1;`
+
+	finishDeploymentQuery = `
+		UPDATE topology_deployments SET status = $2, message = $3, updated_at = NOW(), finished_at = NOW()
+		WHERE deployment_id = $1;`
+
+	failUnfinishedDeploymentsQuery = `
+		UPDATE topology_deployments SET status = $1, message = $2, updated_at = NOW(), finished_at = NOW()
+		WHERE finished_at IS NULL;`
+
+	failDeployingTopologiesQuery = `
+		UPDATE topologies SET deploy_status = $1, updated_at = NOW()
+		WHERE deploy_status = 'deploying';`
+
+	insertChaosExperimentQuery = `
+		INSERT INTO topology_chaos_experiments (experiment_id, topology_id, node_id, namespace, pod_name, action, duration_seconds, status, message, ends_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW() + $7 * INTERVAL '1 second')
+		RETURNING started_at, ends_at;`
+
+	getChaosExperimentByIDQuery = `
+		SELECT experiment_id, topology_id, node_id, namespace, pod_name, action, duration_seconds, status, message, started_at, ends_at, finished_at
+		FROM topology_chaos_experiments WHERE experiment_id = $1;`
+
+	getChaosExperimentsByTopologyQuery = `
+		SELECT experiment_id, topology_id, node_id, namespace, pod_name, action, duration_seconds, status, message, started_at, ends_at, finished_at
+		FROM topology_chaos_experiments WHERE topology_id = $1
+		ORDER BY started_at DESC;`
+
+	getUnfinishedChaosExperimentsQuery = `
+		SELECT experiment_id, topology_id, node_id, namespace, pod_name, action, duration_seconds, status, message, started_at, ends_at, finished_at
+		FROM topology_chaos_experiments WHERE finished_at IS NULL
+		ORDER BY started_at;`
+
+	finishChaosExperimentQuery = `
+		UPDATE topology_chaos_experiments SET status = $2, message = $3, finished_at = NOW()
+		WHERE experiment_id = $1;`
+
+	deleteNodesByTopologyQuery          = `DELETE FROM topology_nodes WHERE topology_id = $1;`
+	deleteEdgesByTopologyQuery          = `DELETE FROM topology_edges WHERE topology_id = $1;`
+	deleteNodePodsByTopologyQuery       = `DELETE FROM topology_node_pods WHERE topology_id = $1;`
+	deleteNodeIdentitiesByTopologyQuery = `DELETE FROM topology_node_identities WHERE topology_id = $1;`
 )
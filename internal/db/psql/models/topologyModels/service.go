@@ -3,10 +3,17 @@ package topologymodels
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	sqlmodelerrors "ipfs-visualizer/internal/db/psql/models"
 )
 
+// queryer is the part of *sql.DB and *sql.Tx the row writers use.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 func CreateTopologyTablesIfNotExist(db *sql.DB) error {
 	if _, err := db.Exec(createTopologiesTable); err != nil {
 		return sqlmodelerrors.NewPostgresModelError("CreateTopologyTablesIfNotExist", "failed to create topologies table", err)
@@ -17,6 +24,18 @@ func CreateTopologyTablesIfNotExist(db *sql.DB) error {
 	if _, err := db.Exec(createTopologyEdgesTable); err != nil {
 		return sqlmodelerrors.NewPostgresModelError("CreateTopologyTablesIfNotExist", "failed to create topology_edges table", err)
 	}
+	if _, err := db.Exec(createTopologyNodeIdentitiesTable); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("CreateTopologyTablesIfNotExist", "failed to create topology_node_identities table", err)
+	}
+	if _, err := db.Exec(createTopologyNodePodsTable); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("CreateTopologyTablesIfNotExist", "failed to create topology_node_pods table", err)
+	}
+	if _, err := db.Exec(createTopologyDeploymentsTable); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("CreateTopologyTablesIfNotExist", "failed to create topology_deployments table", err)
+	}
+	if _, err := db.Exec(createTopologyChaosExperimentsTable); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("CreateTopologyTablesIfNotExist", "failed to create topology_chaos_experiments table", err)
+	}
 	return nil
 }
 
@@ -40,8 +59,9 @@ func GetAllTopologies(ctx context.Context, db *sql.DB) ([]TopologySummaryRow, er
 
 func GetTopologyByID(ctx context.Context, db *sql.DB, id string) (*TopologyModel, error) {
 	var m TopologyModel
+	var settings []byte
 	err := db.QueryRowContext(ctx, getTopologyByIDQuery, id).Scan(
-		&m.TopologyID, &m.Name, &m.DeployStatus, &m.K8sNamespace, &m.CreatedAt, &m.UpdatedAt,
+		&m.TopologyID, &m.Name, &m.DeployStatus, &m.K8sNamespace, &settings, &m.CreatedAt, &m.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -49,12 +69,23 @@ func GetTopologyByID(ctx context.Context, db *sql.DB, id string) (*TopologyModel
 	if err != nil {
 		return nil, sqlmodelerrors.NewPostgresModelError("GetTopologyByID", "query failed", err)
 	}
+	if err := json.Unmarshal(settings, &m.Settings); err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError("GetTopologyByID", "unmarshal settings failed", err)
+	}
 	return &m, nil
 }
 
 func InsertTopology(ctx context.Context, db *sql.DB, m *TopologyModel) error {
-	if err := db.QueryRowContext(ctx, insertTopologyQuery,
-		m.TopologyID, m.Name, m.DeployStatus, m.K8sNamespace,
+	return insertTopology(ctx, db, m)
+}
+
+func insertTopology(ctx context.Context, q queryer, m *TopologyModel) error {
+	settings, err := json.Marshal(m.Settings)
+	if err != nil {
+		return sqlmodelerrors.NewPostgresModelError("InsertTopology", "marshal settings failed", err)
+	}
+	if err := q.QueryRowContext(ctx, insertTopologyQuery,
+		m.TopologyID, m.Name, m.DeployStatus, m.K8sNamespace, settings,
 	).Scan(&m.CreatedAt, &m.UpdatedAt); err != nil {
 		return sqlmodelerrors.NewPostgresModelError("InsertTopology", "insert failed", err)
 	}
@@ -62,10 +93,56 @@ func InsertTopology(ctx context.Context, db *sql.DB, m *TopologyModel) error {
 }
 
 func UpdateTopology(ctx context.Context, db *sql.DB, m *TopologyModel) error {
-	_, err := db.ExecContext(ctx, updateTopologyQuery, m.Name, m.DeployStatus, m.K8sNamespace, m.TopologyID)
+	return updateTopology(ctx, db, m)
+}
+
+func updateTopology(ctx context.Context, q queryer, m *TopologyModel) error {
+	settings, err := json.Marshal(m.Settings)
+	if err != nil {
+		return sqlmodelerrors.NewPostgresModelError("UpdateTopology", "marshal settings failed", err)
+	}
+	_, err = q.ExecContext(ctx, updateTopologyQuery, m.Name, m.DeployStatus, m.K8sNamespace, settings, m.TopologyID)
 	return err
 }
 
+// InsertTopologyGraph inserts a topology row with its nodes, identities and
+// edges in one transaction.
+func InsertTopologyGraph(ctx context.Context, db *sql.DB, m *TopologyModel, g TopologyGraph) error {
+	return saveTopologyGraph(ctx, db, m, g, insertTopology)
+}
+
+// UpdateTopologyGraph updates a topology row and replaces the lists of g that
+// are set in one transaction, so a failed write leaves the topology as it was.
+func UpdateTopologyGraph(ctx context.Context, db *sql.DB, m *TopologyModel, g TopologyGraph) error {
+	return saveTopologyGraph(ctx, db, m, g, updateTopology)
+}
+
+func saveTopologyGraph(ctx context.Context, db *sql.DB, m *TopologyModel, g TopologyGraph, save func(context.Context, queryer, *TopologyModel) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := save(ctx, tx, m); err != nil {
+		return err
+	}
+	if g.Nodes != nil {
+		if err := replaceNodes(ctx, tx, m.TopologyID, g.Nodes); err != nil {
+			return err
+		}
+		if err := replaceNodeIdentities(ctx, tx, m.TopologyID, g.Identities); err != nil {
+			return err
+		}
+	}
+	if g.Edges != nil {
+		if err := replaceEdges(ctx, tx, m.TopologyID, g.Edges); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func UpdateTopologyDeployStatus(ctx context.Context, db *sql.DB, topologyID, status string, namespace *string) error {
 	_, err := db.ExecContext(ctx, `UPDATE topologies SET deploy_status = $1, k8s_namespace = $2, updated_at = NOW() WHERE topology_id = $3`,
 		status, namespace, topologyID)
@@ -87,9 +164,13 @@ func GetNodesByTopology(ctx context.Context, db *sql.DB, topologyID string) ([]T
 	var list []TopologyNodeModel
 	for rows.Next() {
 		var n TopologyNodeModel
-		if err := rows.Scan(&n.TopologyID, &n.NodeID, &n.Label, &n.PosX, &n.PosY, &n.Role); err != nil {
+		var overrides []byte
+		if err := rows.Scan(&n.TopologyID, &n.NodeID, &n.Label, &n.PosX, &n.PosY, &n.Role, &overrides); err != nil {
 			return nil, sqlmodelerrors.NewPostgresModelError("GetNodesByTopology", "scan failed", err)
 		}
+		if err := json.Unmarshal(overrides, &n.Overrides); err != nil {
+			return nil, sqlmodelerrors.NewPostgresModelError("GetNodesByTopology", "unmarshal overrides failed", err)
+		}
 		list = append(list, n)
 	}
 	return list, nil
@@ -120,16 +201,27 @@ func ReplaceTopologyNodes(ctx context.Context, db *sql.DB, topologyID string, no
 	}
 	defer tx.Rollback()
 
+	if err := replaceNodes(ctx, tx, topologyID, nodes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceNodes(ctx context.Context, tx *sql.Tx, topologyID string, nodes []TopologyNodeModel) error {
 	if _, err := tx.ExecContext(ctx, deleteNodesByTopologyQuery, topologyID); err != nil {
 		return err
 	}
 	for _, n := range nodes {
+		overrides, err := json.Marshal(n.Overrides)
+		if err != nil {
+			return sqlmodelerrors.NewPostgresModelError("ReplaceTopologyNodes", "marshal overrides failed", err)
+		}
 		if _, err := tx.ExecContext(ctx, insertNodeQuery,
-			n.TopologyID, n.NodeID, n.Label, n.PosX, n.PosY, n.Role); err != nil {
+			n.TopologyID, n.NodeID, n.Label, n.PosX, n.PosY, n.Role, overrides); err != nil {
 			return err
 		}
 	}
-	return tx.Commit()
+	return nil
 }
 
 func ReplaceTopologyEdges(ctx context.Context, db *sql.DB, topologyID string, edges []TopologyEdgeModel) error {
@@ -139,6 +231,13 @@ func ReplaceTopologyEdges(ctx context.Context, db *sql.DB, topologyID string, ed
 	}
 	defer tx.Rollback()
 
+	if err := replaceEdges(ctx, tx, topologyID, edges); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceEdges(ctx context.Context, tx *sql.Tx, topologyID string, edges []TopologyEdgeModel) error {
 	if _, err := tx.ExecContext(ctx, deleteEdgesByTopologyQuery, topologyID); err != nil {
 		return err
 	}
@@ -148,5 +247,226 @@ func ReplaceTopologyEdges(ctx context.Context, db *sql.DB, topologyID string, ed
 			return err
 		}
 	}
+	return nil
+}
+
+func GetNodeIdentitiesByTopology(ctx context.Context, db *sql.DB, topologyID string) ([]TopologyNodeIdentityModel, error) {
+	rows, err := db.QueryContext(ctx, getNodeIdentitiesByTopologyQuery, topologyID)
+	if err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError("GetNodeIdentitiesByTopology", "query failed", err)
+	}
+	defer rows.Close()
+
+	var list []TopologyNodeIdentityModel
+	for rows.Next() {
+		var i TopologyNodeIdentityModel
+		if err := rows.Scan(&i.TopologyID, &i.NodeID, &i.PeerID, &i.IPFSPeerID); err != nil {
+			return nil, sqlmodelerrors.NewPostgresModelError("GetNodeIdentitiesByTopology", "scan failed", err)
+		}
+		list = append(list, i)
+	}
+	return list, nil
+}
+
+func ReplaceTopologyNodeIdentities(ctx context.Context, db *sql.DB, topologyID string, identities []TopologyNodeIdentityModel) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceNodeIdentities(ctx, tx, topologyID, identities); err != nil {
+		return err
+	}
 	return tx.Commit()
 }
+
+func replaceNodeIdentities(ctx context.Context, tx *sql.Tx, topologyID string, identities []TopologyNodeIdentityModel) error {
+	if _, err := tx.ExecContext(ctx, deleteNodeIdentitiesByTopologyQuery, topologyID); err != nil {
+		return err
+	}
+	for _, i := range identities {
+		if _, err := tx.ExecContext(ctx, insertNodeIdentityQuery,
+			i.TopologyID, i.NodeID, i.PeerID, i.IPFSPeerID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetNodePodsByTopology(ctx context.Context, db *sql.DB, topologyID string) ([]TopologyNodePodModel, error) {
+	rows, err := db.QueryContext(ctx, getNodePodsByTopologyQuery, topologyID)
+	if err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError("GetNodePodsByTopology", "query failed", err)
+	}
+	defer rows.Close()
+
+	var list []TopologyNodePodModel
+	for rows.Next() {
+		var p TopologyNodePodModel
+		if err := rows.Scan(&p.TopologyID, &p.NodeID, &p.PodName, &p.Workload, &p.Ordinal); err != nil {
+			return nil, sqlmodelerrors.NewPostgresModelError("GetNodePodsByTopology", "scan failed", err)
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// ReplaceTopologyNodePods stores the node → pod assignment made by the latest deploy.
+// An empty list clears it.
+func ReplaceTopologyNodePods(ctx context.Context, db *sql.DB, topologyID string, pods []TopologyNodePodModel) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, deleteNodePodsByTopologyQuery, topologyID); err != nil {
+		return err
+	}
+	for _, p := range pods {
+		if _, err := tx.ExecContext(ctx, insertNodePodQuery,
+			p.TopologyID, p.NodeID, p.PodName, p.Workload, p.Ordinal); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func InsertTopologyDeployment(ctx context.Context, db *sql.DB, m *TopologyDeploymentModel) error {
+	steps, err := json.Marshal(m.Steps)
+	if err != nil {
+		return sqlmodelerrors.NewPostgresModelError("InsertTopologyDeployment", "marshal steps failed", err)
+	}
+	if err := db.QueryRowContext(ctx, insertDeploymentQuery,
+		m.DeploymentID, m.TopologyID, m.Namespace, m.Status, m.Message, steps,
+	).Scan(&m.CreatedAt, &m.UpdatedAt); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("InsertTopologyDeployment", "insert failed", err)
+	}
+	return nil
+}
+
+func GetTopologyDeploymentByID(ctx context.Context, db *sql.DB, id string) (*TopologyDeploymentModel, error) {
+	m, err := scanTopologyDeployment(db.QueryRowContext(ctx, getDeploymentByIDQuery, id))
+	if err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError("GetTopologyDeploymentByID", "query failed", err)
+	}
+	return m, nil
+}
+
+// GetLatestTopologyDeployment returns the most recent deployment of a topology, or nil.
+func GetLatestTopologyDeployment(ctx context.Context, db *sql.DB, topologyID string) (*TopologyDeploymentModel, error) {
+	m, err := scanTopologyDeployment(db.QueryRowContext(ctx, getLatestDeploymentByTopologyQuery, topologyID))
+	if err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError("GetLatestTopologyDeployment", "query failed", err)
+	}
+	return m, nil
+}
+
+func AppendTopologyDeploymentStep(ctx context.Context, db *sql.DB, id string, step DeploymentStepModel) error {
+	raw, err := json.Marshal([]DeploymentStepModel{step})
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, appendDeploymentStepQuery, id, raw)
+	return err
+}
+
+// FinishTopologyDeployment sets the final status of a deployment.
+func FinishTopologyDeployment(ctx context.Context, db *sql.DB, id, status, message string) error {
+	_, err := db.ExecContext(ctx, finishDeploymentQuery, id, status, message)
+	return err
+}
+
+// FailUnfinishedTopologyDeployments finishes deployments whose worker is gone,
+// e.g. after a restart of the server, and moves their topologies out of deploying.
+func FailUnfinishedTopologyDeployments(ctx context.Context, db *sql.DB, status, message string) error {
+	if _, err := db.ExecContext(ctx, failUnfinishedDeploymentsQuery, status, message); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("FailUnfinishedTopologyDeployments", "update deployments failed", err)
+	}
+	if _, err := db.ExecContext(ctx, failDeployingTopologiesQuery, status); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("FailUnfinishedTopologyDeployments", "update topologies failed", err)
+	}
+	return nil
+}
+
+func scanTopologyDeployment(row *sql.Row) (*TopologyDeploymentModel, error) {
+	var m TopologyDeploymentModel
+	var steps []byte
+	err := row.Scan(&m.DeploymentID, &m.TopologyID, &m.Namespace, &m.Status, &m.Message, &steps, &m.CreatedAt, &m.UpdatedAt, &m.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(steps, &m.Steps); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func InsertTopologyChaosExperiment(ctx context.Context, db *sql.DB, m *TopologyChaosExperimentModel) error {
+	if err := db.QueryRowContext(ctx, insertChaosExperimentQuery,
+		m.ExperimentID, m.TopologyID, m.NodeID, m.Namespace, m.PodName, m.Action, m.DurationSeconds, m.Status, m.Message,
+	).Scan(&m.StartedAt, &m.EndsAt); err != nil {
+		return sqlmodelerrors.NewPostgresModelError("InsertTopologyChaosExperiment", "insert failed", err)
+	}
+	return nil
+}
+
+func GetTopologyChaosExperimentByID(ctx context.Context, db *sql.DB, id string) (*TopologyChaosExperimentModel, error) {
+	m, err := scanTopologyChaosExperiment(db.QueryRowContext(ctx, getChaosExperimentByIDQuery, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError("GetTopologyChaosExperimentByID", "query failed", err)
+	}
+	return m, nil
+}
+
+// GetTopologyChaosExperiments lists the chaos experiments of a topology, newest first.
+func GetTopologyChaosExperiments(ctx context.Context, db *sql.DB, topologyID string) ([]TopologyChaosExperimentModel, error) {
+	return queryTopologyChaosExperiments(ctx, db, "GetTopologyChaosExperiments", getChaosExperimentsByTopologyQuery, topologyID)
+}
+
+// GetUnfinishedTopologyChaosExperiments lists the experiments that were never
+// reverted, e.g. because the server restarted while they ran.
+func GetUnfinishedTopologyChaosExperiments(ctx context.Context, db *sql.DB) ([]TopologyChaosExperimentModel, error) {
+	return queryTopologyChaosExperiments(ctx, db, "GetUnfinishedTopologyChaosExperiments", getUnfinishedChaosExperimentsQuery)
+}
+
+// FinishTopologyChaosExperiment sets the final status of a chaos experiment.
+func FinishTopologyChaosExperiment(ctx context.Context, db *sql.DB, id, status, message string) error {
+	_, err := db.ExecContext(ctx, finishChaosExperimentQuery, id, status, message)
+	return err
+}
+
+func queryTopologyChaosExperiments(ctx context.Context, db *sql.DB, op, query string, args ...any) ([]TopologyChaosExperimentModel, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqlmodelerrors.NewPostgresModelError(op, "query failed", err)
+	}
+	defer rows.Close()
+
+	var list []TopologyChaosExperimentModel
+	for rows.Next() {
+		m, err := scanTopologyChaosExperiment(rows)
+		if err != nil {
+			return nil, sqlmodelerrors.NewPostgresModelError(op, "scan failed", err)
+		}
+		list = append(list, *m)
+	}
+	return list, rows.Err()
+}
+
+func scanTopologyChaosExperiment(row interface{ Scan(...any) error }) (*TopologyChaosExperimentModel, error) {
+	var m TopologyChaosExperimentModel
+	err := row.Scan(&m.ExperimentID, &m.TopologyID, &m.NodeID, &m.Namespace, &m.PodName, &m.Action, &m.DurationSeconds,
+		&m.Status, &m.Message, &m.StartedAt, &m.EndsAt, &m.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
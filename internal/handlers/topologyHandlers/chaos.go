@@ -0,0 +1,89 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// StartChaos injects a fault into the pod of a node; it is reverted after the
+// requested duration.
+func (h *Handler) StartChaos(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	var req topology.ChaosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	e, err := topology.StartChaos(r.Context(), h.db, h.k8s, h.restCfg, id, chi.URLParam(r, "nodeId"), req)
+	if errors.Is(err, topology.ErrChaosRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("StartChaos", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/chaos/"+e.ExperimentID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+func (h *Handler) ListChaosExperiments(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	list, err := topology.ListChaosExperiments(r.Context(), h.db, id)
+	if err != nil {
+		slog.Error("ListChaosExperiments", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		http.Error(w, "topology not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+func (h *Handler) GetChaosExperiment(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "experimentId")
+	e, err := topology.GetChaosExperiment(r.Context(), h.db, id)
+	if err != nil {
+		slog.Error("GetChaosExperiment", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if e == nil {
+		http.Error(w, "chaos experiment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// StopChaosExperiment reverts a running experiment before its duration is over.
+func (h *Handler) StopChaosExperiment(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "experimentId")
+	e, err := topology.StopChaosExperiment(r.Context(), h.db, id)
+	if errors.Is(err, topology.ErrChaosNotRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("StopChaosExperiment", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if e == nil {
+		http.Error(w, "chaos experiment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,62 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetClusterPeers reports every pod's ipfs-cluster identity and peerset.
+func (h *Handler) GetClusterPeers(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	peers, err := topology.GetClusterPeers(r.Context(), h.db, h.k8s, id)
+	if err != nil {
+		slog.Error("GetClusterPeers", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(peers)
+}
+
+// GetEdgeConformance checks the designed edges against kubo swarm connections.
+func (h *Handler) GetEdgeConformance(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	conformance, err := topology.GetEdgeConformance(r.Context(), h.db, h.k8s, id)
+	if err != nil {
+		slog.Error("GetEdgeConformance", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(conformance)
+}
+
+// deployedErrorStatus maps errors of queries against running nodes. Client
+// errors of the node APIs are passed through, other failures of a node mean a
+// bad gateway.
+func deployedErrorStatus(err error) int {
+	var badRequest *topology.ClusterRequestError
+	var proxyErr *kubetopo.ProxyError
+	switch {
+	case errors.Is(err, topology.ErrTopologyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, topology.ErrTopologyNotDeployed):
+		return http.StatusConflict
+	case errors.Is(err, topology.ErrNodeNotDeployed):
+		return http.StatusNotFound
+	case errors.As(err, &badRequest):
+		return http.StatusBadRequest
+	case errors.As(err, &proxyErr):
+		if proxyErr.StatusCode >= 400 && proxyErr.StatusCode < 500 {
+			return proxyErr.StatusCode
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,137 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AddContent streams a multipart upload into the cluster through one node. The
+// body is passed on as it arrives and never buffered.
+func (h *Handler) AddContent(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	q := r.URL.Query()
+	opts, err := parseAddOptions(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := topology.AddContent(r.Context(), h.db, h.k8s, id, q.Get("nodeId"), r.Header.Get("Content-Type"), r.Body, opts)
+	if err != nil {
+		slog.Error("AddContent", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Node-Id", result.NodeID)
+	w.Header().Set("X-Pod-Name", result.PodName)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// GetContentHeatmap reports which nodes hold a CID and how.
+func (h *Handler) GetContentHeatmap(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	heatmap, err := topology.GetContentHeatmap(r.Context(), h.db, h.k8s, id, chi.URLParam(r, "cid"))
+	if err != nil {
+		slog.Error("GetContentHeatmap", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(heatmap)
+}
+
+func parseAddOptions(q url.Values) (kubetopo.AddOptions, error) {
+	opts := kubetopo.AddOptions{Name: q.Get("name"), Chunker: q.Get("chunker")}
+	ints := map[string]*int{"cidVersion": &opts.CIDVersion, "replicationMin": &opts.ReplicationMin, "replicationMax": &opts.ReplicationMax}
+	for name, dst := range ints {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*dst = n
+		}
+	}
+	if v := q.Get("rawLeaves"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid rawLeaves: %q", v)
+		}
+		opts.RawLeaves = &b
+	}
+	if v := q.Get("wrapWithDirectory"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid wrapWithDirectory: %q", v)
+		}
+		opts.WrapWithDirectory = b
+	}
+	return opts, nil
+}
+
+// gatewayRequestHeaders are passed to the gateway; gatewayResponseHeaders back
+// to the client.
+var (
+	gatewayRequestHeaders  = []string{"Accept", "Range", "If-None-Match", "If-Modified-Since"}
+	gatewayResponseHeaders = []string{
+		"Content-Type", "Content-Length", "Content-Range", "Content-Disposition", "Accept-Ranges",
+		"Etag", "Last-Modified", "Cache-Control", "X-Ipfs-Path", "X-Ipfs-Roots",
+	}
+)
+
+// FetchContent proxies GET/HEAD /ipfs/{cid}/* to the gateway of one node and
+// streams the body. X-Node-Id names the serving node, X-Ttfb-Ms (and
+// Server-Timing) the time until the gateway answered.
+func (h *Handler) FetchContent(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	nodeID := chi.URLParam(r, "nodeId")
+	header := http.Header{}
+	for _, k := range gatewayRequestHeaders {
+		if v := r.Header.Values(k); len(v) > 0 {
+			header[k] = v
+		}
+	}
+	subPath := chi.URLParam(r, "*")
+	if subPath == "" && strings.HasSuffix(r.URL.Path, "/") {
+		subPath = "/" // directory listing of the root
+	}
+	gw, err := topology.FetchContent(r.Context(), h.db, h.k8s, id, nodeID, chi.URLParam(r, "cid"), subPath, r.Method, r.URL.Query(), header)
+	if err != nil {
+		slog.Error("FetchContent", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	resp := gw.Response
+	defer resp.Body.Close()
+
+	for _, k := range gatewayResponseHeaders {
+		if v := resp.Header.Values(k); len(v) > 0 {
+			w.Header()[k] = v
+		}
+	}
+	if loc := resp.Header.Get("Location"); loc != "" {
+		if p, ok := kubetopo.ProxyPath(loc); ok {
+			loc = "/v1/topologies/" + id + "/nodes/" + nodeID + p
+		}
+		w.Header().Set("Location", loc)
+	}
+	ttfb := float64(gw.TTFB.Microseconds()) / 1000
+	w.Header().Set("X-Node-Id", gw.NodeID)
+	w.Header().Set("X-Pod-Name", gw.PodName)
+	w.Header().Set("X-Ttfb-Ms", strconv.FormatFloat(ttfb, 'f', 1, 64))
+	w.Header().Set("Server-Timing", fmt.Sprintf("ttfb;dur=%.1f", ttfb))
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != http.MethodHead {
+		streamBody(w, resp.Body)
+	}
+}
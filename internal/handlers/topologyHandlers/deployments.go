@@ -0,0 +1,47 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *Handler) GetDeployment(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "deploymentId")
+	d, err := topology.GetDeployment(r.Context(), h.db, id)
+	if err != nil {
+		slog.Error("GetDeployment", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if d == nil {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+func (h *Handler) CancelDeployment(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "deploymentId")
+	d, err := topology.CancelDeployment(r.Context(), h.db, id)
+	if errors.Is(err, topology.ErrDeploymentNotRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("CancelDeployment", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if d == nil {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d)
+}
@@ -3,21 +3,47 @@ package topologyhandlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"ipfs-visualizer/config"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
 	"ipfs-visualizer/internal/services/topology"
 	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
 )
 
 type Handler struct {
-	db  *sql.DB
-	k8s *kubernetes.Clientset
+	db         *sql.DB
+	k8s        *kubernetes.Clientset
+	restCfg    *rest.Config
+	kubeCfg    *config.KubeConfig
+	clusterCfg *config.ClusterConfig
+	pods       *kubetopo.PodWatchers
 }
 
-func NewHandler(db *sql.DB, k8s *kubernetes.Clientset) *Handler {
-	return &Handler{db: db, k8s: k8s}
+func NewHandler(db *sql.DB, k8s *kubernetes.Clientset, restCfg *rest.Config, kubeCfg *config.KubeConfig, clusterCfg *config.ClusterConfig) *Handler {
+	return &Handler{db: db, k8s: k8s, restCfg: restCfg, kubeCfg: kubeCfg, clusterCfg: clusterCfg, pods: kubetopo.NewPodWatchers(k8s)}
+}
+
+// workloadDefaults are the server-wide deploy defaults from the CLUSTER_* variables.
+func (h *Handler) workloadDefaults() kubetopo.Workload {
+	return kubetopo.Workload{
+		IPFSImage:    h.clusterCfg.IpfsImage,
+		ClusterImage: h.clusterCfg.IpfsClusterImage,
+		StorageClass: h.clusterCfg.StorageClass,
+		ServiceType:  corev1.ServiceType(h.clusterCfg.ServiceType),
+	}
+}
+
+// apiSources are who the NetworkPolicies of a deploy admit to the API ports, from
+// the KUBE_POLICY_API_* variables.
+func (h *Handler) apiSources() kubetopo.APISources {
+	return kubetopo.APISources{CIDRs: h.kubeCfg.PolicyAPICIDRs, Namespaces: h.kubeCfg.PolicyAPINamespaces}
 }
 
 func (h *Handler) GetAll(w http.ResponseWriter, r *http.Request) {
@@ -60,7 +86,10 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "name is required", http.StatusBadRequest)
 		return
 	}
-	t, err := topology.CreateTopology(ctx, h.db, req)
+	t, err := topology.CreateTopology(ctx, h.db, h.k8s, h.kubeCfg.IdentityNamespace, req)
+	if writeInvalidTopology(w, err) {
+		return
+	}
 	if err != nil {
 		slog.Error("CreateTopology", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -79,7 +108,10 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
-	t, err := topology.UpdateTopology(ctx, h.db, id, req)
+	t, err := topology.UpdateTopology(ctx, h.db, h.k8s, h.kubeCfg.IdentityNamespace, id, req)
+	if writeInvalidTopology(w, err) {
+		return
+	}
 	if err != nil {
 		slog.Error("UpdateTopology", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -93,6 +125,35 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(t)
 }
 
+func (h *Handler) Validate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "topologyId")
+	result, err := topology.ValidateTopologyByID(ctx, h.db, id)
+	if err != nil {
+		slog.Error("ValidateTopology", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result == nil {
+		http.Error(w, "topology not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// writeInvalidTopology answers 400 with the validation issues if err is a validation failure.
+func writeInvalidTopology(w http.ResponseWriter, err error) bool {
+	var invalid *topology.InvalidTopologyError
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(invalid.Result)
+	return true
+}
+
 func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "topologyId")
@@ -106,7 +167,7 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "topology not found", http.StatusNotFound)
 		return
 	}
-	if err := topology.DeleteTopology(ctx, h.db, id); err != nil {
+	if err := topology.DeleteTopology(ctx, h.db, h.k8s, h.kubeCfg.IdentityNamespace, id); err != nil {
 		slog.Error("DeleteTopology", "error", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -114,33 +175,159 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Handler) Deploy(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	id := chi.URLParam(r, "topologyId")
-	namespace := "default"
-	private := false
-	var body struct {
-		Namespace string `json:"namespace"`
-		Private   bool   `json:"private"`
-	}
+type deployRequest struct {
+	Namespace       string `json:"namespace"`
+	Private         bool   `json:"private"`
+	Disconnected    string `json:"disconnected"`
+	NetworkPolicies bool   `json:"networkPolicies"`
+	DryRun          bool   `json:"dryRun"`
+	ServerDryRun    bool   `json:"serverDryRun"`
+}
+
+func (h *Handler) decodeDeployRequest(r *http.Request) (topology.DeployOptions, deployRequest) {
+	opts := topology.DeployOptions{Namespace: "default", Defaults: h.workloadDefaults(), Timeout: h.kubeCfg.DeployTimeout}
+	var body deployRequest
 	_ = json.NewDecoder(r.Body).Decode(&body)
 	if body.Namespace != "" {
-		namespace = body.Namespace
+		opts.Namespace = body.Namespace
 	}
 	if body.Private {
-		private = true
+		opts.Private = true
 	}
-	result, err := topology.DeployTopology(ctx, h.db, h.k8s, id, namespace, private)
-	if err != nil {
+	opts.Disconnected = body.Disconnected
+	opts.NetworkPolicies = body.NetworkPolicies
+	opts.APISources = h.apiSources()
+	return opts, body
+}
+
+func (h *Handler) Deploy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "topologyId")
+	opts, body := h.decodeDeployRequest(r)
+	if body.DryRun || body.ServerDryRun {
+		h.writePlan(w, r, id, opts, body.ServerDryRun)
+		return
+	}
+	result, err := topology.DeployTopology(ctx, h.db, h.k8s, h.kubeCfg.IdentityNamespace, id, opts)
+	if writeInvalidTopology(w, err) {
+		return
+	}
+	status := http.StatusAccepted
+	var failed *topology.DeploymentFailedError
+	switch {
+	case errors.As(err, &failed):
+		// The deployment was recorded: its step log tells what went wrong.
 		slog.Error("DeployTopology", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		status = http.StatusInternalServerError
+		result = &topology.DeployResult{
+			TopologyID:   id,
+			DeploymentID: failed.DeploymentID,
+			Status:       topology.DeploymentError,
+			Message:      err.Error(),
+		}
+	case err != nil:
+		slog.Error("DeployTopology", "error", err)
+		http.Error(w, err.Error(), deployErrorStatus(err))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
+	w.Header().Set("Location", "/v1/deployments/"+result.DeploymentID)
+	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(result)
 }
 
+// deployErrorStatus maps errors of deploys and plans: request and topology
+// errors are the client's, anything else is a server failure.
+func deployErrorStatus(err error) int {
+	var badRequest *topology.DeployRequestError
+	var disconnected *topology.DisconnectedTopologyError
+	var missingBootstrap *topology.MissingBootstrapError
+	switch {
+	case errors.Is(err, topology.ErrTopologyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, topology.ErrDeploymentSuperseded):
+		return http.StatusConflict
+	case errors.As(err, &badRequest), errors.As(err, &disconnected), errors.As(err, &missingBootstrap):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) Plan(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	opts, body := h.decodeDeployRequest(r)
+	h.writePlan(w, r, id, opts, body.ServerDryRun)
+}
+
+// writePlan renders the deploy plan as JSON or, with ?format=yaml, as YAML.
+func (h *Handler) writePlan(w http.ResponseWriter, r *http.Request, id string, opts topology.DeployOptions, serverDryRun bool) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "yaml" {
+		http.Error(w, "format must be json or yaml", http.StatusBadRequest)
+		return
+	}
+	plan, err := topology.PlanDeployment(r.Context(), h.db, h.k8s, h.kubeCfg.IdentityNamespace, id, opts, serverDryRun)
+	if writeInvalidTopology(w, err) {
+		return
+	}
+	if err != nil {
+		slog.Error("PlanDeployment", "error", err)
+		http.Error(w, err.Error(), deployErrorStatus(err))
+		return
+	}
+	if format == "yaml" {
+		out, err := yaml.Marshal(plan)
+		if err != nil {
+			slog.Error("PlanDeployment", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(out)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(plan)
+}
+
+// ExportManifests returns the topology as manifests, a Helm chart or a Kustomize
+// base. Image, storage and service type query parameters override the topology settings.
+func (h *Handler) ExportManifests(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	q := r.URL.Query()
+	opts := topology.DeployOptions{
+		Namespace:       "default",
+		Private:         q.Get("private") == "true",
+		Disconnected:    q.Get("disconnected"),
+		NetworkPolicies: q.Get("networkPolicies") == "true",
+		APISources:      h.apiSources(),
+		Workload: kubetopo.Workload{
+			IPFSImage:          q.Get("ipfsImage"),
+			ClusterImage:       q.Get("clusterImage"),
+			StorageClass:       q.Get("storageClass"),
+			IPFSStorageSize:    q.Get("ipfsStorage"),
+			ClusterStorageSize: q.Get("clusterStorage"),
+			ServiceType:        corev1.ServiceType(q.Get("serviceType")),
+		},
+		Defaults: h.workloadDefaults(),
+	}
+	if ns := q.Get("namespace"); ns != "" {
+		opts.Namespace = ns
+	}
+	manifests, err := topology.ExportManifests(r.Context(), h.db, h.k8s, h.kubeCfg.IdentityNamespace, id, q.Get("format"), opts)
+	if writeInvalidTopology(w, err) {
+		return
+	}
+	if err != nil {
+		slog.Error("ExportManifests", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", manifests.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+manifests.FileName+`"`)
+	_, _ = w.Write(manifests.Data)
+}
+
 func (h *Handler) Undeploy(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "topologyId")
@@ -165,21 +352,16 @@ func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(status)
 }
 
-func (h *Handler) GetPodLogs(w http.ResponseWriter, r *http.Request) {
+// GetEvents lists the Kubernetes events of the topology objects, newest first.
+func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	topologyID := chi.URLParam(r, "topologyId")
-	podName := chi.URLParam(r, "podName")
-	container := r.URL.Query().Get("container")
-	if podName == "" {
-		http.Error(w, "podName required", http.StatusBadRequest)
-		return
-	}
-	logs, err := topology.GetPodLogs(ctx, h.db, h.k8s, topologyID, podName, container)
+	id := chi.URLParam(r, "topologyId")
+	events, err := topology.GetTopologyEvents(ctx, h.db, h.k8s, id)
 	if err != nil {
-		slog.Error("GetPodLogs", "error", err)
-		http.Error(w, err.Error(), http.StatusNotFound)
+		slog.Error("GetTopologyEvents", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
 		return
 	}
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Write([]byte(logs))
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
 }
@@ -0,0 +1,130 @@
+package topologyhandlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const streamChunkSize = 32 * 1024
+
+func (h *Handler) GetPodLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	topologyID := chi.URLParam(r, "topologyId")
+	podName := chi.URLParam(r, "podName")
+	if podName == "" {
+		http.Error(w, "podName required", http.StatusBadRequest)
+		return
+	}
+	opts, err := parseLogOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs, err := topology.GetPodLogs(ctx, h.db, h.k8s, topologyID, podName, opts)
+	if err != nil {
+		slog.Error("GetPodLogs", "error", err)
+		http.Error(w, err.Error(), logErrorStatus(err))
+		return
+	}
+	writePodLogs(w, logs)
+}
+
+func (h *Handler) GetNodeLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	topologyID := chi.URLParam(r, "topologyId")
+	nodeID := chi.URLParam(r, "nodeId")
+	opts, err := parseLogOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logs, err := topology.GetNodeLogs(ctx, h.db, h.k8s, topologyID, nodeID, opts)
+	if err != nil {
+		slog.Error("GetNodeLogs", "error", err)
+		http.Error(w, err.Error(), logErrorStatus(err))
+		return
+	}
+	writePodLogs(w, logs)
+}
+
+// parseLogOptions reads container, follow, previous, timestamps, tailLines and
+// sinceSeconds from the query string.
+func parseLogOptions(r *http.Request) (kubetopo.LogOptions, error) {
+	q := r.URL.Query()
+	opts := kubetopo.LogOptions{Container: q.Get("container")}
+	flags := map[string]*bool{"follow": &opts.Follow, "previous": &opts.Previous, "timestamps": &opts.Timestamps}
+	for name, dst := range flags {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*dst = b
+		}
+	}
+	counts := map[string]**int64{"tailLines": &opts.TailLines, "sinceSeconds": &opts.SinceSeconds}
+	for name, dst := range counts {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 || (name == "sinceSeconds" && n == 0) {
+				return opts, fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*dst = &n
+		}
+	}
+	return opts, nil
+}
+
+// logErrorStatus passes through errors of the Kubernetes API (e.g. 400 for
+// previous=true without a terminated container); anything else means the
+// topology, pod or node is unknown.
+func logErrorStatus(err error) int {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		return int(status.Status().Code)
+	}
+	return http.StatusNotFound
+}
+
+// writePodLogs copies the log stream as it arrives, flushing every chunk so
+// follow=true reaches the client without buffering.
+func writePodLogs(w http.ResponseWriter, logs *topology.PodLogs) {
+	defer logs.Stream.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set("X-Node-Id", logs.NodeID)
+	w.Header().Set("X-Pod-Name", logs.PodName)
+	w.WriteHeader(http.StatusOK)
+	streamBody(w, logs.Stream)
+}
+
+// streamBody copies r to the client chunk by chunk, flushing each one.
+func streamBody(w http.ResponseWriter, r io.Reader) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AddPin pins a CID in the topology's ipfs-cluster.
+func (h *Handler) AddPin(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	var req topology.PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	pin, err := topology.AddPin(r.Context(), h.db, h.k8s, id, req)
+	if err != nil {
+		slog.Error("AddPin", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(pin)
+}
+
+func (h *Handler) ListPins(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	pins, err := topology.ListPins(r.Context(), h.db, h.k8s, id, r.URL.Query().Get("nodeId"))
+	if err != nil {
+		slog.Error("ListPins", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pins)
+}
+
+func (h *Handler) GetPin(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	pin, err := topology.GetPin(r.Context(), h.db, h.k8s, id, chi.URLParam(r, "cid"), r.URL.Query().Get("nodeId"))
+	if err != nil {
+		slog.Error("GetPin", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pin)
+}
+
+func (h *Handler) RemovePin(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "topologyId")
+	pin, err := topology.RemovePin(r.Context(), h.db, h.k8s, id, chi.URLParam(r, "cid"), r.URL.Query().Get("nodeId"))
+	if err != nil {
+		slog.Error("RemovePin", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pin)
+}
@@ -0,0 +1,54 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSettings returns the deploy settings of a topology with their effective values.
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "topologyId")
+	view, err := topology.GetTopologySettings(ctx, h.db, id, h.workloadDefaults())
+	if err != nil {
+		slog.Error("GetTopologySettings", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if view == nil {
+		http.Error(w, "topology not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(view)
+}
+
+// UpdateSettings replaces the deploy settings of a topology. They apply on the next deploy.
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "topologyId")
+	var req topology.TopologySettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	view, err := topology.UpdateTopologySettings(ctx, h.db, h.k8s, h.kubeCfg.IdentityNamespace, id, req, h.workloadDefaults())
+	if writeInvalidTopology(w, err) {
+		return
+	}
+	if err != nil {
+		slog.Error("UpdateTopologySettings", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if view == nil {
+		http.Error(w, "topology not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(view)
+}
@@ -0,0 +1,66 @@
+package topologyhandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"ipfs-visualizer/internal/services/topology"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+// StreamStatus pushes pod phase, readiness and restart changes as Server-Sent
+// Events. Existing pods are sent first as "added".
+func (h *Handler) StreamStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "topologyId")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	events, err := topology.StreamStatus(ctx, h.db, h.pods, id)
+	if err != nil {
+		slog.Error("StreamStatus", "error", err)
+		http.Error(w, err.Error(), deployedErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				slog.Error("StreamStatus", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: pod\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// FieldManager is the server-side apply field manager of all topology objects.
+const FieldManager = "ipfs-visualizer"
+
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+	ActionDeleted   = "deleted"
+)
+
+// ObjectChange reports what an apply did to one object.
+type ObjectChange struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// applyObject server-side applies obj and reports whether it was created, updated
+// or left unchanged, judging by its resourceVersion. With dryRun nothing is
+// persisted and the action is left empty.
+func applyObject(ctx context.Context, client kubernetes.Interface, namespace string, obj runtime.Object, dryRun bool) (string, error) {
+	data, err := applyPatch(obj)
+	if err != nil {
+		return "", err
+	}
+	name := obj.(metav1.Object).GetName()
+	opts := metav1.PatchOptions{FieldManager: FieldManager, Force: boolPtr(true)}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	var before, after string
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		c := client.CoreV1().ConfigMaps(namespace)
+		if before, err = currentVersion(c.Get(ctx, name, metav1.GetOptions{})); err != nil {
+			return "", err
+		}
+		res, err := c.Patch(ctx, name, types.ApplyPatchType, data, opts)
+		if err != nil {
+			return "", err
+		}
+		after = res.ResourceVersion
+	case *corev1.Secret:
+		c := client.CoreV1().Secrets(namespace)
+		if before, err = currentVersion(c.Get(ctx, name, metav1.GetOptions{})); err != nil {
+			return "", err
+		}
+		res, err := c.Patch(ctx, name, types.ApplyPatchType, data, opts)
+		if err != nil {
+			return "", err
+		}
+		after = res.ResourceVersion
+	case *corev1.Service:
+		c := client.CoreV1().Services(namespace)
+		if before, err = currentVersion(c.Get(ctx, name, metav1.GetOptions{})); err != nil {
+			return "", err
+		}
+		res, err := c.Patch(ctx, name, types.ApplyPatchType, data, opts)
+		if err != nil {
+			return "", err
+		}
+		after = res.ResourceVersion
+	case *appsv1.StatefulSet:
+		c := client.AppsV1().StatefulSets(namespace)
+		current, err := c.Get(ctx, name, metav1.GetOptions{})
+		if before, err = currentVersion(current, err); err != nil {
+			return "", err
+		}
+		if before != "" {
+			if err := checkClaimTemplates(current, o); err != nil {
+				return "", err
+			}
+			if !reflect.DeepEqual(current.Spec.Selector, o.Spec.Selector) {
+				// Selectors are immutable: a default StatefulSet deployed
+				// before it excluded the profile pods keeps app=<svc> and
+				// tells its pods apart by their controller reference.
+				o = o.DeepCopy()
+				o.Spec.Selector = current.Spec.Selector
+				if data, err = applyPatch(o); err != nil {
+					return "", err
+				}
+			}
+		}
+		res, err := c.Patch(ctx, name, types.ApplyPatchType, data, opts)
+		if err != nil {
+			return "", err
+		}
+		after = res.ResourceVersion
+	case *networkingv1.NetworkPolicy:
+		c := client.NetworkingV1().NetworkPolicies(namespace)
+		if before, err = currentVersion(c.Get(ctx, name, metav1.GetOptions{})); err != nil {
+			return "", err
+		}
+		res, err := c.Patch(ctx, name, types.ApplyPatchType, data, opts)
+		if err != nil {
+			return "", err
+		}
+		after = res.ResourceVersion
+	default:
+		return "", fmt.Errorf("unsupported object type %T", obj)
+	}
+
+	switch {
+	case dryRun:
+		return "", nil
+	case before == "":
+		return ActionCreated, nil
+	case before != after:
+		return ActionUpdated, nil
+	default:
+		return ActionUnchanged, nil
+	}
+}
+
+// applyPatch renders obj as a server-side apply patch.
+func applyPatch(obj runtime.Object) ([]byte, error) {
+	doc, err := manifestDoc(obj, false)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// currentVersion returns the resourceVersion of an existing object, or "" if it is not found.
+func currentVersion(obj metav1.Object, err error) (string, error) {
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return obj.GetResourceVersion(), nil
+}
+
+// checkClaimTemplates rejects a change to the volumeClaimTemplates of an existing
+// StatefulSet, which the API server refuses with an opaque 422: the storage class
+// and sizes of deployed volumes only change with new PVCs.
+func checkClaimTemplates(current, desired *appsv1.StatefulSet) error {
+	existing := make(map[string]corev1.PersistentVolumeClaim, len(current.Spec.VolumeClaimTemplates))
+	for _, claim := range current.Spec.VolumeClaimTemplates {
+		existing[claim.Name] = claim
+	}
+	var changes []string
+	for _, claim := range desired.Spec.VolumeClaimTemplates {
+		was, ok := existing[claim.Name]
+		if !ok {
+			changes = append(changes, claim.Name+" added")
+			continue
+		}
+		delete(existing, claim.Name)
+		if from, to := deref(was.Spec.StorageClassName), deref(claim.Spec.StorageClassName); from != to {
+			changes = append(changes, fmt.Sprintf("%s storage class %q -> %q", claim.Name, from, to))
+		}
+		if from, to := was.Spec.Resources.Requests.Storage(), claim.Spec.Resources.Requests.Storage(); from.Cmp(*to) != 0 {
+			changes = append(changes, fmt.Sprintf("%s size %s -> %s", claim.Name, from, to))
+		}
+	}
+	for name := range existing {
+		changes = append(changes, name+" removed")
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	sort.Strings(changes)
+	return fmt.Errorf("volumeClaimTemplates of a deployed StatefulSet cannot change (%s): "+
+		"revert the storage settings, or undeploy the topology and delete its PVCs to recreate the volumes",
+		strings.Join(changes, ", "))
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func boolPtr(b bool) *bool { return &b }
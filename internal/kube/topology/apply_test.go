@@ -0,0 +1,110 @@
+package topology
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testStatefulSet is the default StatefulSet of the test topology.
+func testStatefulSet(t *testing.T) *appsv1.StatefulSet {
+	t.Helper()
+	cfg, _ := testDeployConfig(t)
+	objects, _ := buildTestObjects(t, cfg)
+	return objects.StatefulSets[0]
+}
+
+func TestCheckClaimTemplates(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(sts *appsv1.StatefulSet)
+		want   string // substring of the error, "" = no error
+	}{
+		{
+			name:   "unchanged",
+			change: func(*appsv1.StatefulSet) {},
+		},
+		{
+			name: "pod template changes are allowed",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.Template.Spec.Containers[0].Image = "ipfs/kubo:v0.30.0"
+				*sts.Spec.Replicas = 5
+			},
+		},
+		{
+			name: "same size written differently",
+			change: func(sts *appsv1.StatefulSet) {
+				q := sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage]
+				sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse(q.AsDec().String())
+			},
+		},
+		{
+			name: "storage class",
+			change: func(sts *appsv1.StatefulSet) {
+				class := "fast"
+				sts.Spec.VolumeClaimTemplates[0].Spec.StorageClassName = &class
+			},
+			want: `cluster-storage storage class "standard" -> "fast"`,
+		},
+		{
+			name: "size",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.VolumeClaimTemplates[1].Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("20Gi")
+			},
+			want: "ipfs-storage size 30Gi -> 20Gi",
+		},
+		{
+			name: "claim added",
+			change: func(sts *appsv1.StatefulSet) {
+				claim := *sts.Spec.VolumeClaimTemplates[0].DeepCopy()
+				claim.Name = "extra"
+				sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, claim)
+			},
+			want: "extra added",
+		},
+		{
+			name: "claim removed",
+			change: func(sts *appsv1.StatefulSet) {
+				sts.Spec.VolumeClaimTemplates = sts.Spec.VolumeClaimTemplates[:1]
+			},
+			want: "ipfs-storage removed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := testStatefulSet(t)
+			desired := current.DeepCopy()
+			tt.change(desired)
+			err := checkClaimTemplates(current, desired)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkClaimTemplates() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkClaimTemplates() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyObjectRejectsClaimTemplateChanges(t *testing.T) {
+	deployed := testStatefulSet(t)
+	// The fake tracker leaves resourceVersion empty, which applyObject reads
+	// as a StatefulSet that is not deployed yet.
+	deployed.ResourceVersion = "1"
+	client := fake.NewClientset(deployed)
+
+	resized := testStatefulSet(t)
+	resized.Spec.VolumeClaimTemplates[1].Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("500Gi")
+	_, err := applyObject(context.Background(), client, resized.Namespace, resized, false)
+	if err == nil || !strings.Contains(err.Error(), "ipfs-storage size 30Gi -> 500Gi") {
+		t.Errorf("applyObject() error = %v, want the volumeClaimTemplates error", err)
+	}
+}
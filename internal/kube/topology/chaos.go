@@ -0,0 +1,165 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Chaos actions on the pod of a canvas node.
+const (
+	ChaosKill         = "kill"          // delete the pod and keep deleting it while the experiment runs
+	ChaosIsolate      = "isolate"       // cut the pod off the swarm with a NetworkPolicy
+	ChaosPauseCluster = "pause-cluster" // SIGSTOP the ipfs-cluster daemon
+	ChaosFillDisk     = "fill-disk"     // fill the kubo volume with a junk file
+)
+
+// ChaosActions lists the supported chaos actions.
+var ChaosActions = []string{ChaosKill, ChaosIsolate, ChaosPauseCluster, ChaosFillDisk}
+
+// ChaosLabel marks a pod isolated by a chaos experiment. The edge policies do
+// not select such pods, so the isolating policy is the only one that applies.
+const ChaosLabel = "ipfs-visualizer/chaos"
+
+const (
+	chaosPolicy   = "chaos"
+	chaosFillFile = "/data/ipfs/chaos-fill"
+)
+
+// ChaosTarget is the pod a chaos experiment acts on.
+type ChaosTarget struct {
+	TopologyID string
+	Namespace  string
+	PodName    string
+}
+
+// IsChaosAction reports whether action is a supported chaos action.
+func IsChaosAction(action string) bool {
+	return slices.Contains(ChaosActions, action)
+}
+
+// InjectChaos starts a fault in the target pod; RevertChaos undoes it.
+func InjectChaos(ctx context.Context, client kubernetes.Interface, restCfg *rest.Config, t ChaosTarget, action string) error {
+	switch action {
+	case ChaosKill:
+		return KillPod(ctx, client, t)
+	case ChaosIsolate:
+		return isolatePod(ctx, client, t)
+	case ChaosPauseCluster:
+		_, err := execInPod(ctx, client, restCfg, t.Namespace, t.PodName, "ipfs-cluster",
+			[]string{"sh", "-c", "kill -STOP $(pidof ipfs-cluster-service)"})
+		return err
+	case ChaosFillDisk:
+		// fallocate is instant where the filesystem supports it; dd writes
+		// until the volume is full otherwise.
+		_, err := execInPod(ctx, client, restCfg, t.Namespace, t.PodName, "ipfs", []string{"sh", "-c",
+			`avail=$(df -Pk /data/ipfs | awk 'NR==2 {print $4}')
+fallocate -l "${avail}k" ` + chaosFillFile + ` 2>/dev/null || dd if=/dev/zero of=` + chaosFillFile + ` bs=1M 2>/dev/null || true`})
+		return err
+	}
+	return fmt.Errorf("unknown chaos action %q", action)
+}
+
+// RevertChaos undoes a fault started by InjectChaos. A killed pod needs nothing:
+// its StatefulSet recreates it once the experiment stops deleting it.
+func RevertChaos(ctx context.Context, client kubernetes.Interface, restCfg *rest.Config, t ChaosTarget, action string) error {
+	switch action {
+	case ChaosKill:
+		return nil
+	case ChaosIsolate:
+		return unisolatePod(ctx, client, t)
+	case ChaosPauseCluster:
+		// A pod recreated in the meantime runs an unpaused daemon.
+		if _, err := client.CoreV1().Pods(t.Namespace).Get(ctx, t.PodName, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			return nil
+		}
+		_, err := execInPod(ctx, client, restCfg, t.Namespace, t.PodName, "ipfs-cluster",
+			[]string{"sh", "-c", "kill -CONT $(pidof ipfs-cluster-service)"})
+		return err
+	case ChaosFillDisk:
+		_, err := execInPod(ctx, client, restCfg, t.Namespace, t.PodName, "ipfs", []string{"rm", "-f", chaosFillFile})
+		return err
+	}
+	return fmt.Errorf("unknown chaos action %q", action)
+}
+
+// KillPod deletes the target pod without a grace period. A pod that is already
+// gone is not an error.
+func KillPod(ctx context.Context, client kubernetes.Interface, t ChaosTarget) error {
+	grace := int64(0)
+	err := client.CoreV1().Pods(t.Namespace).Delete(ctx, t.PodName, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete pod/%s: %w", t.PodName, err)
+	}
+	return nil
+}
+
+// isolatePod applies a NetworkPolicy that denies all egress of the pod and
+// admits ingress only on the API and gateway ports, from the sources its edge
+// policy admits there, then labels the pod so its edge policy stops admitting
+// the swarm traffic of its neighbours.
+func isolatePod(ctx context.Context, client kubernetes.Interface, t ChaosTarget) error {
+	svcName := ServiceName(t.TopologyID)
+	var apiFrom []networkingv1.NetworkPolicyPeer
+	edges, err := client.NetworkingV1().NetworkPolicies(t.Namespace).Get(ctx, t.PodName+"-edges", metav1.GetOptions{})
+	switch {
+	case err == nil && len(edges.Spec.Ingress) > 0:
+		apiFrom = edges.Spec.Ingress[0].From
+	case err != nil && !apierrors.IsNotFound(err):
+		return fmt.Errorf("get networkpolicy/%s-edges: %w", t.PodName, err)
+	}
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      chaosPolicyName(t.PodName),
+			Namespace: t.Namespace,
+			Labels:    map[string]string{"app": svcName, PolicyLabel: chaosPolicy},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *podsSelector(svcName, []string{t.PodName}),
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: apiFrom, Ports: apiPolicyPorts()}},
+		},
+	}
+	if _, err := applyObject(ctx, client, t.Namespace, policy, false); err != nil {
+		return fmt.Errorf("apply networkpolicy/%s: %w", policy.Name, err)
+	}
+	return LabelIsolated(ctx, client, t)
+}
+
+// LabelIsolated puts the isolation label on the target pod. A pod recreated by
+// its StatefulSet comes back without it, so an isolating experiment re-applies
+// it while it runs; a pod that is gone for the moment is not an error.
+func LabelIsolated(ctx context.Context, client kubernetes.Interface, t ChaosTarget) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, ChaosLabel, ChaosIsolate)
+	_, err := client.CoreV1().Pods(t.Namespace).Patch(ctx, t.PodName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("label pod/%s: %w", t.PodName, err)
+	}
+	return nil
+}
+
+func unisolatePod(ctx context.Context, client kubernetes.Interface, t ChaosTarget) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:null}}}`, ChaosLabel)
+	_, err := client.CoreV1().Pods(t.Namespace).Patch(ctx, t.PodName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unlabel pod/%s: %w", t.PodName, err)
+	}
+	name := chaosPolicyName(t.PodName)
+	err = client.NetworkingV1().NetworkPolicies(t.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete networkpolicy/%s: %w", name, err)
+	}
+	return nil
+}
+
+func chaosPolicyName(podName string) string {
+	return podName + "-" + chaosPolicy
+}
@@ -0,0 +1,132 @@
+package topology
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// chaosFixture deploys the edge policies of the test topology with API sources
+// into a fake cluster next to the pod of node b, and returns the pod's target
+// and its edge policy.
+func chaosFixture(t *testing.T) (*fake.Clientset, ChaosTarget, *networkingv1.NetworkPolicy) {
+	t.Helper()
+	cfg, _ := testDeployConfig(t)
+	cfg.APISources = APISources{Namespaces: []string{"visualizer"}}
+	objects, pods := buildTestObjects(t, cfg)
+	target := ChaosTarget{TopologyID: cfg.TopologyID, Namespace: cfg.Namespace, PodName: pods["b"]}
+
+	var edges *networkingv1.NetworkPolicy
+	existing := []runtime.Object{&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      target.PodName,
+		Namespace: target.Namespace,
+		Labels:    map[string]string{"app": ServiceName(cfg.TopologyID), appsv1.StatefulSetPodNameLabel: target.PodName},
+	}}}
+	for _, np := range objects.NetworkPolicies {
+		if np.Name == target.PodName+"-edges" {
+			edges = np
+		}
+		existing = append(existing, np)
+	}
+	return fake.NewClientset(existing...), target, edges
+}
+
+func podLabels(t *testing.T, client *fake.Clientset, target ChaosTarget) labels.Set {
+	t.Helper()
+	pod, err := client.CoreV1().Pods(target.Namespace).Get(context.Background(), target.PodName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod: %v", err)
+	}
+	return pod.Labels
+}
+
+func TestIsolateAndRevert(t *testing.T) {
+	ctx := context.Background()
+	client, target, edges := chaosFixture(t)
+	edgeSelector, err := metav1.LabelSelectorAsSelector(&edges.Spec.PodSelector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edgeSelector.Matches(podLabels(t, client, target)) {
+		t.Fatal("the edge policy does not select the pod before the experiment")
+	}
+
+	if err := InjectChaos(ctx, client, nil, target, ChaosIsolate); err != nil {
+		t.Fatalf("InjectChaos() error = %v", err)
+	}
+	policy, err := client.NetworkingV1().NetworkPolicies(target.Namespace).Get(ctx, target.PodName+"-chaos", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get chaos policy: %v", err)
+	}
+	wantTypes := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}
+	if !reflect.DeepEqual(policy.Spec.PolicyTypes, wantTypes) || len(policy.Spec.Egress) != 0 {
+		t.Errorf("chaos policy types = %v, egress = %v, want all egress denied", policy.Spec.PolicyTypes, policy.Spec.Egress)
+	}
+	if len(policy.Spec.Ingress) != 1 || !reflect.DeepEqual(policy.Spec.Ingress[0], edges.Spec.Ingress[0]) {
+		t.Errorf("chaos ingress = %+v, want the API rule of the edge policy %+v", policy.Spec.Ingress, edges.Spec.Ingress[0])
+	}
+	isolated := podLabels(t, client, target)
+	if isolated[ChaosLabel] != ChaosIsolate {
+		t.Errorf("pod labels = %v, want %s=%s", isolated, ChaosLabel, ChaosIsolate)
+	}
+	if edgeSelector.Matches(isolated) {
+		t.Error("the edge policy still selects the isolated pod")
+	}
+
+	if err := RevertChaos(ctx, client, nil, target, ChaosIsolate); err != nil {
+		t.Fatalf("RevertChaos() error = %v", err)
+	}
+	if _, err := client.NetworkingV1().NetworkPolicies(target.Namespace).Get(ctx, target.PodName+"-chaos", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("chaos policy after revert: error = %v, want not found", err)
+	}
+	if !edgeSelector.Matches(podLabels(t, client, target)) {
+		t.Error("the edge policy does not select the pod after revert")
+	}
+	// Reverting twice, as a restarted server may, is not an error.
+	if err := RevertChaos(ctx, client, nil, target, ChaosIsolate); err != nil {
+		t.Errorf("second RevertChaos() error = %v", err)
+	}
+}
+
+func TestKillPod(t *testing.T) {
+	ctx := context.Background()
+	client, target, _ := chaosFixture(t)
+	for i := 0; i < 2; i++ {
+		if err := InjectChaos(ctx, client, nil, target, ChaosKill); err != nil {
+			t.Fatalf("kill #%d: %v", i+1, err)
+		}
+	}
+	if _, err := client.CoreV1().Pods(target.Namespace).Get(ctx, target.PodName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("pod after kill: error = %v, want not found", err)
+	}
+	if err := RevertChaos(ctx, client, nil, target, ChaosKill); err != nil {
+		t.Errorf("RevertChaos(kill) error = %v", err)
+	}
+}
+
+func TestChaosActions(t *testing.T) {
+	for _, action := range ChaosActions {
+		if !IsChaosAction(action) {
+			t.Errorf("IsChaosAction(%q) = false", action)
+		}
+	}
+	if IsChaosAction("reboot") {
+		t.Error(`IsChaosAction("reboot") = true`)
+	}
+	client, target, _ := chaosFixture(t)
+	if err := InjectChaos(context.Background(), client, nil, target, "reboot"); err == nil {
+		t.Error("InjectChaos(reboot) error = nil")
+	}
+	if err := RevertChaos(context.Background(), client, nil, target, "reboot"); err == nil {
+		t.Error("RevertChaos(reboot) error = nil")
+	}
+}
@@ -0,0 +1,248 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClusterID is what an ipfs-cluster peer reports about itself on GET /id of its
+// REST API, including the peerset it currently sees.
+type ClusterID struct {
+	ID           string   `json:"id"`
+	PeerName     string   `json:"peername"`
+	Version      string   `json:"version"`
+	Commit       string   `json:"commit"`
+	Addresses    []string `json:"addresses"`
+	ClusterPeers []string `json:"cluster_peers"`
+	Error        string   `json:"error"`
+	IPFS         struct {
+		ID        string   `json:"id"`
+		Addresses []string `json:"addresses"`
+		Error     string   `json:"error"`
+	} `json:"ipfs"`
+}
+
+// GetClusterID queries the ipfs-cluster REST API of a pod through the API server.
+func GetClusterID(ctx context.Context, client kubernetes.Interface, namespace, podName string) (*ClusterID, error) {
+	var id ClusterID
+	if err := proxyJSON(ctx, client, namespace, podName, PortClusterAPI, ProxyRequest{Path: "/id"}, &id); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// ClusterCID is a CID as the cluster API encodes it: a plain string or, since
+// ipfs-cluster 1.0, an IPLD link {"/": "<cid>"}.
+type ClusterCID string
+
+func (c *ClusterCID) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = ClusterCID(s)
+		return nil
+	}
+	var link struct {
+		CID string `json:"/"`
+	}
+	if err := json.Unmarshal(b, &link); err != nil {
+		return err
+	}
+	*c = ClusterCID(link.CID)
+	return nil
+}
+
+// PinOptions are the pin parameters forwarded to POST /pins/{cid}.
+type PinOptions struct {
+	Name           string
+	ReplicationMin int // 0 keeps the cluster default, -1 pins everywhere
+	ReplicationMax int
+	Metadata       map[string]string
+}
+
+// ClusterPin is a pin as the cluster stores it.
+type ClusterPin struct {
+	CID            ClusterCID        `json:"cid"`
+	Name           string            `json:"name"`
+	Allocations    []string          `json:"allocations"` // cluster peer IDs
+	ReplicationMin int               `json:"replication_factor_min"`
+	ReplicationMax int               `json:"replication_factor_max"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+// GlobalPinInfo is the status of a pin on every cluster peer.
+type GlobalPinInfo struct {
+	CID         ClusterCID             `json:"cid"`
+	Name        string                 `json:"name"`
+	Allocations []string               `json:"allocations"`
+	Metadata    map[string]string      `json:"metadata"`
+	PeerMap     map[string]PeerPinInfo `json:"peer_map"` // by cluster peer ID
+}
+
+// PeerPinInfo is the status of a pin on one peer: pinned, pinning, pin_queued,
+// pin_error, unpinning, remote, ...
+type PeerPinInfo struct {
+	PeerName  string `json:"peername"`
+	IPFSPeer  string `json:"ipfs_peer_id"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	Error     string `json:"error"`
+}
+
+// Pin adds a pin through the cluster API of a pod.
+func Pin(ctx context.Context, client kubernetes.Interface, namespace, podName, cid string, opts PinOptions) (*ClusterPin, error) {
+	q := url.Values{}
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+	}
+	if opts.ReplicationMin != 0 {
+		q.Set("replication-min", strconv.Itoa(opts.ReplicationMin))
+	}
+	if opts.ReplicationMax != 0 {
+		q.Set("replication-max", strconv.Itoa(opts.ReplicationMax))
+	}
+	for k, v := range opts.Metadata {
+		q.Set("meta-"+k, v)
+	}
+	var pin ClusterPin
+	req := ProxyRequest{Method: http.MethodPost, Path: "/pins/" + cid, Query: q}
+	if err := proxyJSON(ctx, client, namespace, podName, PortClusterAPI, req, &pin); err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// Unpin removes a pin from the cluster.
+func Unpin(ctx context.Context, client kubernetes.Interface, namespace, podName, cid string) (*ClusterPin, error) {
+	var pin ClusterPin
+	req := ProxyRequest{Method: http.MethodDelete, Path: "/pins/" + cid}
+	if err := proxyJSON(ctx, client, namespace, podName, PortClusterAPI, req, &pin); err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// GetPinStatus returns the status of one pin on every peer.
+func GetPinStatus(ctx context.Context, client kubernetes.Interface, namespace, podName, cid string) (*GlobalPinInfo, error) {
+	var info GlobalPinInfo
+	if err := proxyJSON(ctx, client, namespace, podName, PortClusterAPI, ProxyRequest{Path: "/pins/" + cid}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ListPinStatus returns the status of all pins. ipfs-cluster 1.0 streams them
+// as newline-delimited JSON, older versions answer with an array; both are read.
+func ListPinStatus(ctx context.Context, client kubernetes.Interface, namespace, podName string) ([]GlobalPinInfo, error) {
+	resp, err := ProxyPod(ctx, client, namespace, podName, PortClusterAPI, ProxyRequest{Path: "/pins"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := proxyError(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeStream[GlobalPinInfo](resp.Body)
+}
+
+// decodeStream reads a cluster API answer that is either newline-delimited JSON
+// objects or a JSON array of them.
+func decodeStream[T any](r io.Reader) ([]T, error) {
+	out := []T{}
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 && raw[0] == '[' {
+			var batch []T
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return nil, err
+			}
+			out = append(out, batch...)
+			continue
+		}
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+// AddOptions are the import parameters forwarded to POST /add.
+type AddOptions struct {
+	Name              string
+	CIDVersion        int
+	Chunker           string // e.g. size-262144, rabin-min-avg-max
+	RawLeaves         *bool  // nil keeps the default of the CID version
+	WrapWithDirectory bool
+	ReplicationMin    int
+	ReplicationMax    int
+}
+
+// AddedOutput is one imported file or directory; the root comes last.
+type AddedOutput struct {
+	Name        string     `json:"name"`
+	CID         ClusterCID `json:"cid"`
+	Size        uint64     `json:"size"`
+	Allocations []string   `json:"allocations"`
+}
+
+// Add streams a multipart body into the cluster /add endpoint of a pod, which
+// imports it into its kubo node and pins the result in the cluster.
+func Add(ctx context.Context, client kubernetes.Interface, namespace, podName, contentType string, body io.Reader, opts AddOptions) ([]AddedOutput, error) {
+	q := url.Values{}
+	q.Set("cid-version", strconv.Itoa(opts.CIDVersion))
+	if opts.Name != "" {
+		q.Set("name", opts.Name)
+	}
+	if opts.Chunker != "" {
+		q.Set("chunker", opts.Chunker)
+	}
+	if opts.RawLeaves != nil {
+		q.Set("raw-leaves", strconv.FormatBool(*opts.RawLeaves))
+	}
+	if opts.WrapWithDirectory {
+		q.Set("wrap-with-directory", "true")
+	}
+	if opts.ReplicationMin != 0 {
+		q.Set("replication-min", strconv.Itoa(opts.ReplicationMin))
+	}
+	if opts.ReplicationMax != 0 {
+		q.Set("replication-max", strconv.Itoa(opts.ReplicationMax))
+	}
+
+	resp, err := ProxyPod(ctx, client, namespace, podName, PortClusterAPI, ProxyRequest{
+		Method: http.MethodPost,
+		Path:   "/add",
+		Query:  q,
+		Header: http.Header{"Content-Type": {contentType}},
+		Body:   body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := proxyError(resp); err != nil {
+		return nil, err
+	}
+	out, err := decodeStream[AddedOutput](resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// Errors after the stream started are reported in a trailer.
+	if msg := resp.Trailer.Get("X-Stream-Error"); msg != "" {
+		return nil, &ProxyError{StatusCode: http.StatusInternalServerError, Message: msg}
+	}
+	return out, nil
+}
@@ -0,0 +1,66 @@
+package topology
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeStream(t *testing.T) {
+	type item struct {
+		CID string `json:"cid"`
+	}
+	tests := []struct {
+		name    string
+		body    string
+		want    []item
+		wantErr bool
+	}{
+		{
+			name: "newline-delimited objects",
+			body: "{\"cid\":\"a\"}\n{\"cid\":\"b\"}\n",
+			want: []item{{"a"}, {"b"}},
+		},
+		{
+			name: "array",
+			body: `[{"cid":"a"},{"cid":"b"}]`,
+			want: []item{{"a"}, {"b"}},
+		},
+		{
+			name: "objects and arrays mixed",
+			body: "{\"cid\":\"a\"}\n[{\"cid\":\"b\"}]\n{\"cid\":\"c\"}",
+			want: []item{{"a"}, {"b"}, {"c"}},
+		},
+		{
+			name: "empty body",
+			body: "",
+			want: []item{},
+		},
+		{
+			name: "empty array",
+			body: "[]\n",
+			want: []item{},
+		},
+		{
+			name:    "truncated object",
+			body:    "{\"cid\":\"a\"}\n{\"cid\":",
+			wantErr: true,
+		},
+		{
+			name:    "element of the wrong type",
+			body:    `[{"cid":1}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeStream[item](strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeStream() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
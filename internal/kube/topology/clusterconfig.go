@@ -0,0 +1,155 @@
+package topology
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Consensus components of ipfs-cluster.
+const (
+	ConsensusCRDT = "crdt"
+	ConsensusRaft = "raft"
+)
+
+// Metrics the balanced allocator can allocate by, provided by the informers of
+// the generated service.json.
+var clusterAllocateBy = []string{"tag:group", "freespace", "reposize", "numpin", "pinqueue"}
+
+// ClusterConfig parameterises the service.json of the ipfs-cluster peers. Empty
+// fields fall back to DefaultClusterConfig.
+type ClusterConfig struct {
+	Consensus            string   // crdt | raft
+	ReplicationFactorMin int      // -1 = every peer
+	ReplicationFactorMax int      // -1 = every peer
+	TrustedPeers         []string // canvas node IDs or "*"; crdt only
+	MonitorPingInterval  string   // how often a peer sends its metrics
+	MonitorCheckInterval string   // how often the monitor checks for expired metrics
+	AllocateBy           []string // metrics of the balanced allocator, in priority order
+}
+
+// DefaultClusterConfig returns the values of ipfs-cluster-service init, apart
+// from the ping interval, which the deploy has always raised to 3m.
+func DefaultClusterConfig() ClusterConfig {
+	return ClusterConfig{
+		Consensus:            ConsensusCRDT,
+		ReplicationFactorMin: -1,
+		ReplicationFactorMax: -1,
+		TrustedPeers:         []string{"*"},
+		MonitorPingInterval:  "3m",
+		MonitorCheckInterval: "15s",
+		AllocateBy:           []string{"tag:group", "freespace"},
+	}
+}
+
+// Or returns c with its empty fields taken from fallback.
+func (c ClusterConfig) Or(fallback ClusterConfig) ClusterConfig {
+	c.Consensus = cmp.Or(c.Consensus, fallback.Consensus)
+	c.ReplicationFactorMin = cmp.Or(c.ReplicationFactorMin, fallback.ReplicationFactorMin)
+	c.ReplicationFactorMax = cmp.Or(c.ReplicationFactorMax, fallback.ReplicationFactorMax)
+	if len(c.TrustedPeers) == 0 && c.Consensus != ConsensusRaft {
+		c.TrustedPeers = fallback.TrustedPeers
+	}
+	c.MonitorPingInterval = cmp.Or(c.MonitorPingInterval, fallback.MonitorPingInterval)
+	c.MonitorCheckInterval = cmp.Or(c.MonitorCheckInterval, fallback.MonitorCheckInterval)
+	if len(c.AllocateBy) == 0 {
+		c.AllocateBy = fallback.AllocateBy
+	}
+	return c
+}
+
+// Validate checks the fields that are set. Trusted peers are checked against the
+// nodes on deploy.
+func (c ClusterConfig) Validate() error {
+	switch c.Consensus {
+	case "", ConsensusCRDT, ConsensusRaft:
+	default:
+		return fmt.Errorf("unknown consensus %q (expected crdt or raft)", c.Consensus)
+	}
+	rmin, rmax := c.ReplicationFactorMin, c.ReplicationFactorMax
+	if rmin < -1 || rmax < -1 {
+		return fmt.Errorf("replication factors must be -1 or positive")
+	}
+	if rmin == -1 && rmax > 0 {
+		return fmt.Errorf("replication factor min -1 requires max -1")
+	}
+	if rmin > 0 && rmax > 0 && rmin > rmax {
+		return fmt.Errorf("replication factor min %d exceeds max %d", rmin, rmax)
+	}
+	if len(c.TrustedPeers) > 0 && c.Consensus == ConsensusRaft {
+		return fmt.Errorf("trusted peers only apply to crdt consensus")
+	}
+	for _, d := range []string{c.MonitorPingInterval, c.MonitorCheckInterval} {
+		if d == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d); err != nil || v <= 0 {
+			return fmt.Errorf("invalid interval %q", d)
+		}
+	}
+	disk := 0
+	for _, m := range c.AllocateBy {
+		if !slices.Contains(clusterAllocateBy, m) {
+			return fmt.Errorf("unknown allocator metric %q (expected one of %s)", m, strings.Join(clusterAllocateBy, ", "))
+		}
+		if m == "freespace" || m == "reposize" {
+			disk++
+		}
+	}
+	if disk > 1 {
+		return fmt.Errorf("allocator can use only one of freespace and reposize")
+	}
+	return nil
+}
+
+// serviceJSON renders the service.json shared by the peers of a topology. The
+// peer name, identity and network secret come from the pod's environment;
+// trustedPeers are cluster peer IDs or "*".
+func (c ClusterConfig) serviceJSON(trustedPeers []string) (string, error) {
+	consensus := map[string]any{ConsensusRaft: map[string]any{}}
+	if c.Consensus == ConsensusCRDT {
+		consensus = map[string]any{ConsensusCRDT: map[string]any{
+			"cluster_name":  "ipfs-cluster",
+			"trusted_peers": trustedPeers,
+		}}
+	}
+	diskMetric := "freespace"
+	if slices.Contains(c.AllocateBy, "reposize") {
+		diskMetric = "reposize"
+	}
+	cfg := map[string]any{
+		"cluster": map[string]any{
+			"listen_multiaddress":    []string{"/ip4/0.0.0.0/tcp/9096"},
+			"replication_factor_min": c.ReplicationFactorMin,
+			"replication_factor_max": c.ReplicationFactorMax,
+			"monitor_ping_interval":  c.MonitorPingInterval,
+		},
+		"consensus": consensus,
+		"api": map[string]any{
+			"restapi": map[string]any{"http_listen_multiaddress": "/ip4/0.0.0.0/tcp/9094"},
+			"ipfsproxy": map[string]any{
+				"listen_multiaddress": "/ip4/0.0.0.0/tcp/9095",
+				"node_multiaddress":   "/ip4/127.0.0.1/tcp/5001",
+			},
+		},
+		"ipfs_connector": map[string]any{"ipfshttp": map[string]any{"node_multiaddress": "/ip4/127.0.0.1/tcp/5001"}},
+		"pin_tracker":    map[string]any{"stateless": map[string]any{}},
+		"monitor":        map[string]any{"pubsubmon": map[string]any{"check_interval": c.MonitorCheckInterval}},
+		"allocator":      map[string]any{"balanced": map[string]any{"allocate_by": c.AllocateBy}},
+		"informer": map[string]any{
+			"disk":     map[string]any{"metric_type": diskMetric},
+			"numpin":   map[string]any{},
+			"pinqueue": map[string]any{},
+			"tags":     map[string]any{"tags": map[string]string{"group": "default"}},
+		},
+		"datastore": map[string]any{"pebble": map[string]any{}},
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
@@ -0,0 +1,89 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClusterConfigOr(t *testing.T) {
+	def := DefaultClusterConfig()
+	tests := []struct {
+		name string
+		c    ClusterConfig
+		want ClusterConfig
+	}{
+		{
+			name: "empty takes the fallback",
+			c:    ClusterConfig{},
+			want: def,
+		},
+		{
+			name: "set fields are kept",
+			c: ClusterConfig{
+				ReplicationFactorMin: 2,
+				ReplicationFactorMax: 3,
+				TrustedPeers:         []string{"a", "b"},
+				MonitorPingInterval:  "30s",
+				AllocateBy:           []string{"numpin"},
+			},
+			want: ClusterConfig{
+				Consensus:            ConsensusCRDT,
+				ReplicationFactorMin: 2,
+				ReplicationFactorMax: 3,
+				TrustedPeers:         []string{"a", "b"},
+				MonitorPingInterval:  "30s",
+				MonitorCheckInterval: def.MonitorCheckInterval,
+				AllocateBy:           []string{"numpin"},
+			},
+		},
+		{
+			name: "raft takes no trusted peers",
+			c:    ClusterConfig{Consensus: ConsensusRaft},
+			want: ClusterConfig{
+				Consensus:            ConsensusRaft,
+				ReplicationFactorMin: def.ReplicationFactorMin,
+				ReplicationFactorMax: def.ReplicationFactorMax,
+				MonitorPingInterval:  def.MonitorPingInterval,
+				MonitorCheckInterval: def.MonitorCheckInterval,
+				AllocateBy:           def.AllocateBy,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.Or(def); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Or() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       ClusterConfig
+		wantErr bool
+	}{
+		{name: "defaults", c: DefaultClusterConfig()},
+		{name: "empty", c: ClusterConfig{}},
+		{name: "raft", c: ClusterConfig{Consensus: ConsensusRaft, ReplicationFactorMin: 1, ReplicationFactorMax: 2}},
+		{name: "unknown consensus", c: ClusterConfig{Consensus: "paxos"}, wantErr: true},
+		{name: "replication factor below -1", c: ClusterConfig{ReplicationFactorMin: -2}, wantErr: true},
+		{name: "min every peer with a bounded max", c: ClusterConfig{ReplicationFactorMin: -1, ReplicationFactorMax: 3}, wantErr: true},
+		{name: "bounded min with max every peer", c: ClusterConfig{ReplicationFactorMin: 2, ReplicationFactorMax: -1}},
+		{name: "min above max", c: ClusterConfig{ReplicationFactorMin: 3, ReplicationFactorMax: 2}, wantErr: true},
+		{name: "trusted peers with raft", c: ClusterConfig{Consensus: ConsensusRaft, TrustedPeers: []string{"*"}}, wantErr: true},
+		{name: "unparsable interval", c: ClusterConfig{MonitorPingInterval: "soon"}, wantErr: true},
+		{name: "zero interval", c: ClusterConfig{MonitorCheckInterval: "0s"}, wantErr: true},
+		{name: "unknown allocator metric", c: ClusterConfig{AllocateBy: []string{"tag:region"}}, wantErr: true},
+		{name: "two disk metrics", c: ClusterConfig{AllocateBy: []string{"freespace", "reposize"}}, wantErr: true},
+		{name: "one disk metric among others", c: ClusterConfig{AllocateBy: []string{"pinqueue", "reposize", "numpin"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,16 +2,22 @@ package topology
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"ipfs-visualizer/internal/kube"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 )
@@ -23,7 +29,10 @@ type NodeInfo struct {
 		X float64 `json:"x"`
 		Y float64 `json:"y"`
 	}
-	Role string
+	Role      string
+	Identity  *NodeIdentity // persisted identity; generated for this deploy when nil
+	Overrides NodeOverrides
+	Kubo      KuboConfig // merged over the topology's kubo config
 }
 
 type EdgeInfo struct {
@@ -32,70 +41,226 @@ type EdgeInfo struct {
 }
 
 type DeployConfig struct {
-	TopologyID  string
-	Name        string
-	Namespace   string
-	Nodes       []NodeInfo
-	Edges       []EdgeInfo
-	BootstrapID string
-	Private     bool // true = ClusterIP (доступ только внутри K8s), false = LoadBalancer
+	TopologyID   string
+	Name         string
+	Namespace    string
+	Nodes        []NodeInfo
+	Edges        []EdgeInfo
+	BootstrapIDs []string   // placed at the lowest ordinals
+	Networks     [][]string // node IDs of each independent ipfs-cluster network; empty = one network
+	Private      bool       // true = ClusterIP (доступ только внутри K8s), false = LoadBalancer
+	Workload     Workload
+	Kubo         KuboConfig    // merged over DefaultKuboConfig
+	Cluster      ClusterConfig // empty fields fall back to DefaultClusterConfig
+	// PrivateNetwork puts the kubo nodes of each network on a swarm key and off
+	// the public bootstrap peers.
+	PrivateNetwork bool
+	// NetworkPolicies restricts the swarm ports of every pod to its edge neighbours.
+	NetworkPolicies bool
+	// APISources may reach the API and gateway ports under NetworkPolicies;
+	// none leaves those ports open to everyone.
+	APISources APISources
+	// SwarmKeys are the persisted swarm keys of the networks, by network index;
+	// missing ones are generated for this deploy.
+	SwarmKeys []string
+	// ClusterSecrets are the persisted secrets of the networks, by network index;
+	// missing ones are generated for this deploy.
+	ClusterSecrets []string
+	// Assignments are the node pods of the previous deploy; nodes keep their
+	// ordinals where they can.
+	Assignments []PodAssignment
 }
 
-func Deploy(ctx context.Context, client kubernetes.Interface, cfg DeployConfig) error {
-	keyPair, err := kube.GenerateBootstrapPrivateKey()
-	if err != nil {
-		return fmt.Errorf("generate bootstrap key: %w", err)
+// ConfigHashAnnotation on the pod template holds the hash of the pod configuration.
+const ConfigHashAnnotation = "ipfs-visualizer/config-hash"
+
+// ServiceName is the base name shared by all Kubernetes objects of a topology.
+func ServiceName(topologyID string) string {
+	return "ipfs-" + strings.ReplaceAll(topologyID, "-", "")[:12]
+}
+
+// PodAssignment records which pod of which workload a canvas node was deployed as.
+type PodAssignment struct {
+	NodeID   string
+	PodName  string
+	Workload string
+	Ordinal  int
+}
+
+// Objects are the Kubernetes objects a topology deploy consists of, in creation order.
+type Objects struct {
+	ConfigMaps   []*corev1.ConfigMap
+	Secrets      []*corev1.Secret
+	Services     []*corev1.Service
+	StatefulSets []*appsv1.StatefulSet
+	// NetworkPolicies are only rendered with DeployConfig.NetworkPolicies.
+	NetworkPolicies []*networkingv1.NetworkPolicy
+}
+
+// List returns all objects in creation order.
+func (o *Objects) List() []runtime.Object {
+	out := make([]runtime.Object, 0, len(o.ConfigMaps)+len(o.Secrets)+len(o.Services)+len(o.StatefulSets)+len(o.NetworkPolicies))
+	for _, cm := range o.ConfigMaps {
+		out = append(out, cm)
+	}
+	for _, s := range o.Secrets {
+		out = append(out, s)
 	}
-	clusterSecret, err := kube.GenerateClusterSecret()
+	for _, s := range o.Services {
+		out = append(out, s)
+	}
+	for _, sts := range o.StatefulSets {
+		out = append(out, sts)
+	}
+	for _, np := range o.NetworkPolicies {
+		out = append(out, np)
+	}
+	return out
+}
+
+// BuildObjects renders every object of a topology deploy without touching the cluster.
+func BuildObjects(cfg DeployConfig) (*Objects, []PodAssignment, error) {
+	svcName := ServiceName(cfg.TopologyID)
+	workload, err := cfg.Workload.resolve(cfg.Private)
 	if err != nil {
-		return fmt.Errorf("generate cluster secret: %w", err)
+		return nil, nil, err
+	}
+	cluster := cfg.Cluster.Or(DefaultClusterConfig())
+	if err := cluster.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("cluster config: %w", err)
 	}
 
-	svcName := "ipfs-" + strings.ReplaceAll(cfg.TopologyID, "-", "")[:12]
+	ordered := orderNodes(cfg)
+	profiles, profileByNode := nodeProfiles(svcName, ordered)
+	peers, err := newPeers(ordered, profileByNode, networkIndex(cfg), cfg.Assignments)
+	if err != nil {
+		return nil, nil, err
+	}
+	byNode := make(map[string]peer, len(peers))
+	for _, p := range peers {
+		byNode[p.NodeID] = p
+	}
+	targets := outgoingTargets(cfg)
 
-	entrypointScript := getEntrypointScript(svcName, keyPair.PeerID)
-	configureIPFSScript := getConfigureIPFSScript()
+	trusted := make([]string, 0, len(cluster.TrustedPeers))
+	for _, nodeID := range cluster.TrustedPeers {
+		if nodeID == "*" {
+			trusted = append(trusted, nodeID)
+			continue
+		}
+		p, ok := byNode[nodeID]
+		if !ok {
+			return nil, nil, fmt.Errorf("trusted peer %s is not a node of the topology", nodeID)
+		}
+		trusted = append(trusted, p.ClusterKey.PeerID)
+	}
+	serviceJSON, err := cluster.serviceJSON(trusted)
+	if err != nil {
+		return nil, nil, fmt.Errorf("render service.json: %w", err)
+	}
 
 	cm := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
 		ObjectMeta: metav1.ObjectMeta{Name: svcName + "-scripts", Namespace: cfg.Namespace},
 		Data: map[string]string{
-			"entrypoint.sh":     entrypointScript,
-			"configure-ipfs.sh": configureIPFSScript,
+			"entrypoint.sh":     getEntrypointScript(),
+			"configure-ipfs.sh": getConfigureIPFSScript(),
 		},
 	}
-	if _, err := client.CoreV1().ConfigMaps(cfg.Namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return fmt.Errorf("create configmap: %w", err)
-		}
+
+	clusterCM := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: svcName + "-cluster", Namespace: cfg.Namespace},
+		Data:       map[string]string{"service.json": serviceJSON},
 	}
 
 	envCM := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
 		ObjectMeta: metav1.ObjectMeta{Name: svcName + "-env", Namespace: cfg.Namespace},
-		Data: map[string]string{
-			"bootstrap-peer-id": keyPair.PeerID,
-		},
+		Data:       map[string]string{},
 	}
-	if _, err := client.CoreV1().ConfigMaps(cfg.Namespace).Create(ctx, envCM, metav1.CreateOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return fmt.Errorf("create env configmap: %w", err)
+	clusterSecrets := make([]string, max(len(cfg.Networks), 1))
+	for i := range clusterSecrets {
+		if i < len(cfg.ClusterSecrets) {
+			clusterSecrets[i] = cfg.ClusterSecrets[i]
+			continue
+		}
+		if clusterSecrets[i], err = kube.GenerateClusterSecret(); err != nil {
+			return nil, nil, fmt.Errorf("generate cluster secret: %w", err)
 		}
 	}
-
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{Name: svcName + "-secrets", Namespace: cfg.Namespace},
-		Type:       corev1.SecretTypeOpaque,
-		Data: map[string][]byte{
-			"cluster-secret":          []byte(clusterSecret),
-			"bootstrap-peer-priv-key": []byte(keyPair.PrivateKey),
-		},
+	var swarmKeys []string
+	if cfg.PrivateNetwork {
+		swarmKeys = make([]string, len(clusterSecrets))
+	}
+	for i := range swarmKeys {
+		if i < len(cfg.SwarmKeys) {
+			swarmKeys[i] = cfg.SwarmKeys[i]
+			continue
+		}
+		if swarmKeys[i], err = kube.GenerateClusterSecret(); err != nil {
+			return nil, nil, fmt.Errorf("generate swarm key: %w", err)
+		}
+	}
+	if len(profiles) == 0 {
+		profiles = []*profile{{Name: svcName, Replicas: 1}}
 	}
-	if _, err := client.CoreV1().Secrets(cfg.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return fmt.Errorf("create secret: %w", err)
+	// Every StatefulSet mounts a Secret with the keys of its own pods and the
+	// secrets of the networks they join, not the keys of the whole topology.
+	// Pods of one StatefulSet share the template, so a node with overrides,
+	// alone in its StatefulSet, sees only its own keys.
+	secrets := make([]*corev1.Secret, 0, len(profiles))
+	secretByWorkload := make(map[string]*corev1.Secret, len(profiles))
+	for _, p := range profiles {
+		secret := &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      keysSecretName(p.Name),
+				Namespace: cfg.Namespace,
+				Labels:    map[string]string{"app": svcName},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{},
+		}
+		if len(p.Nodes) == 0 {
+			secret.Data[clusterSecretKey(0)] = []byte(clusterSecrets[0])
 		}
+		secrets = append(secrets, secret)
+		secretByWorkload[p.Name] = secret
+	}
+	for _, p := range peers {
+		secret := secretByWorkload[p.Workload]
+		secret.Data[clusterSecretKey(p.Network)] = []byte(clusterSecrets[p.Network])
+		if cfg.PrivateNetwork {
+			secret.Data[swarmKeyKey(p.Network)] = []byte(swarmKeys[p.Network])
+		}
+		secret.Data[p.PodName+".cluster-priv-key"] = []byte(p.ClusterKey.PrivateKey)
+		secret.Data[p.PodName+".ipfs-priv-key"] = []byte(p.IPFSKey.PrivateKey)
+	}
+	kubo := DefaultKuboConfig().Merge(cfg.Kubo)
+	kuboByNode := make(map[string]KuboConfig, len(ordered))
+	for _, n := range ordered {
+		nk := kubo.Merge(n.Kubo)
+		if err := nk.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("kubo config of node %s: %w", n.NodeID, err)
+		}
+		if cfg.PrivateNetwork {
+			nk = nk.Merge(privateNetworkKubo())
+		}
+		kuboByNode[n.NodeID] = nk
+	}
+	for _, p := range peers {
+		nk := kuboByNode[p.NodeID]
+		env, err := peerEnv(svcName, p, byNode, targets[p.NodeID], nk, cfg.PrivateNetwork)
+		if err != nil {
+			return nil, nil, fmt.Errorf("render env for node %s: %w", p.NodeID, err)
+		}
+		envCM.Data[p.PodName+".env"] = env
+		envCM.Data[p.PodName+".kubo-config"] = kuboConfigScript(nk)
 	}
 
 	headlessSvc := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
 		ObjectMeta: metav1.ObjectMeta{Name: svcName, Namespace: cfg.Namespace},
 		Spec: corev1.ServiceSpec{
 			ClusterIP: "None",
@@ -112,20 +277,12 @@ func Deploy(ctx context.Context, client kubernetes.Interface, cfg DeployConfig)
 			},
 		},
 	}
-	if _, err := client.CoreV1().Services(cfg.Namespace).Create(ctx, headlessSvc, metav1.CreateOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return fmt.Errorf("create headless service: %w", err)
-		}
-	}
 
-	externalSvcType := corev1.ServiceTypeLoadBalancer
-	if cfg.Private {
-		externalSvcType = corev1.ServiceTypeClusterIP
-	}
 	externalSvc := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
 		ObjectMeta: metav1.ObjectMeta{Name: svcName + "-external", Namespace: cfg.Namespace},
 		Spec: corev1.ServiceSpec{
-			Type:     externalSvcType,
+			Type:     workload.ServiceType,
 			Selector: map[string]string{"app": svcName},
 			Ports: []corev1.ServicePort{
 				{Name: "swarm", Port: 4001, TargetPort: intstr.FromInt(4001)},
@@ -139,30 +296,293 @@ func Deploy(ctx context.Context, client kubernetes.Interface, cfg DeployConfig)
 			},
 		},
 	}
-	if _, err := client.CoreV1().Services(cfg.Namespace).Create(ctx, externalSvc, metav1.CreateOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return fmt.Errorf("create external service: %w", err)
+
+	// Pods only read the scripts, configs, env files and keys on start, so a
+	// change in them has to roll the StatefulSets.
+	hashed := []runtime.Object{cm, clusterCM, envCM}
+	for _, secret := range secrets {
+		hashed = append(hashed, secret)
+	}
+	hash := configHash(hashed...)
+	statefulSets := make([]*appsv1.StatefulSet, 0, len(profiles))
+	for _, p := range profiles {
+		pw := p.Overrides.apply(workload)
+		if err := pw.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("overrides of nodes %s: %w", strings.Join(p.Nodes, ", "), err)
+		}
+		sts := buildStatefulSet(p.Name, svcName, cfg.Namespace, p.Replicas, pw)
+		applyOverrides(sts, p, svcName)
+		if cfg.PrivateNetwork {
+			forcePrivateNetwork(sts)
 		}
+		sts.Spec.Template.Annotations[ConfigHashAnnotation] = hash
+		statefulSets = append(statefulSets, sts)
 	}
 
-	replicas := int32(len(cfg.Nodes))
-	if replicas < 1 {
-		replicas = 1
+	assignments := make([]PodAssignment, 0, len(peers))
+	for _, p := range peers {
+		assignments = append(assignments, PodAssignment{NodeID: p.NodeID, PodName: p.PodName, Workload: p.Workload, Ordinal: p.Ordinal})
 	}
+	objects := &Objects{
+		ConfigMaps:   []*corev1.ConfigMap{cm, clusterCM, envCM},
+		Secrets:      secrets,
+		Services:     []*corev1.Service{headlessSvc, externalSvc},
+		StatefulSets: statefulSets,
+	}
+	if cfg.NetworkPolicies {
+		objects.NetworkPolicies, err = buildNetworkPolicies(cfg, svcName, peers, byNode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("networkpolicies: %w", err)
+		}
+	}
+	return objects, assignments, nil
+}
 
-	sts := buildStatefulSet(svcName, cfg.Namespace, replicas, keyPair.PeerID)
-	if _, err := client.AppsV1().StatefulSets(cfg.Namespace).Create(ctx, sts, metav1.CreateOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return fmt.Errorf("create statefulset: %w", err)
+// Deploy server-side applies the topology objects, so a redeploy updates existing
+// objects to match the saved topology, and deletes the StatefulSets and key
+// Secrets of profiles no node uses anymore. It returns the pod assignments and what was done to each
+// object.
+func Deploy(ctx context.Context, client kubernetes.Interface, cfg DeployConfig) ([]PodAssignment, []ObjectChange, error) {
+	objects, assignments, err := BuildObjects(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes := make([]ObjectChange, 0, len(objects.List()))
+	for _, obj := range objects.List() {
+		action, err := applyObject(ctx, client, cfg.Namespace, obj, false)
+		if err != nil {
+			return nil, changes, fmt.Errorf("apply %s: %w", objectRef(obj), err)
 		}
+		changes = append(changes, ObjectChange{
+			Kind:   obj.GetObjectKind().GroupVersionKind().Kind,
+			Name:   obj.(metav1.Object).GetName(),
+			Action: action,
+		})
+	}
+	keep := make(map[string]bool, len(objects.StatefulSets))
+	for _, sts := range objects.StatefulSets {
+		keep[sts.Name] = true
+	}
+	pruned, err := pruneStatefulSets(ctx, client, cfg.TopologyID, cfg.Namespace, keep)
+	if err != nil {
+		return nil, changes, err
 	}
+	for _, name := range pruned {
+		changes = append(changes, ObjectChange{Kind: "StatefulSet", Name: name, Action: ActionDeleted})
+	}
+	keep = make(map[string]bool, len(objects.Secrets))
+	for _, secret := range objects.Secrets {
+		keep[secret.Name] = true
+	}
+	pruned, err = pruneSecrets(ctx, client, ServiceName(cfg.TopologyID), cfg.Namespace, keep)
+	if err != nil {
+		return nil, changes, err
+	}
+	for _, name := range pruned {
+		changes = append(changes, ObjectChange{Kind: "Secret", Name: name, Action: ActionDeleted})
+	}
+	keep = make(map[string]bool, len(objects.NetworkPolicies))
+	for _, np := range objects.NetworkPolicies {
+		keep[np.Name] = true
+	}
+	pruned, err = pruneNetworkPolicies(ctx, client, ServiceName(cfg.TopologyID), cfg.Namespace, keep)
+	if err != nil {
+		return nil, changes, err
+	}
+	for _, name := range pruned {
+		changes = append(changes, ObjectChange{Kind: "NetworkPolicy", Name: name, Action: ActionDeleted})
+	}
+	return assignments, changes, nil
+}
 
-	return nil
+// listStatefulSets returns the StatefulSets of a topology that are not being
+// deleted. Deploys before profiles created <svc> without the app label, so it
+// is looked up by name as well.
+func listStatefulSets(ctx context.Context, client kubernetes.Interface, topologyID, namespace string) ([]appsv1.StatefulSet, error) {
+	svcName := ServiceName(topologyID)
+	list, err := client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=" + svcName})
+	if err != nil {
+		return nil, err
+	}
+	var out []appsv1.StatefulSet
+	seen := false
+	for _, sts := range list.Items {
+		if sts.DeletionTimestamp != nil || !isTopologyWorkload(svcName, sts.Name) {
+			continue
+		}
+		seen = seen || sts.Name == svcName
+		out = append(out, sts)
+	}
+	if !seen {
+		sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, svcName, metav1.GetOptions{})
+		switch {
+		case err == nil && sts.DeletionTimestamp == nil:
+			out = append(out, *sts)
+		case err != nil && !apierrors.IsNotFound(err):
+			return nil, err
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// pruneStatefulSets deletes the topology StatefulSets not in keep. Their PVCs
+// are left in place, as on Undeploy: a redeploy never deletes node data.
+func pruneStatefulSets(ctx context.Context, client kubernetes.Interface, topologyID, namespace string, keep map[string]bool) ([]string, error) {
+	existing, err := listStatefulSets(ctx, client, topologyID, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	propagation := metav1.DeletePropagationForeground
+	var pruned []string
+	for _, sts := range existing {
+		if keep[sts.Name] {
+			continue
+		}
+		err := client.AppsV1().StatefulSets(namespace).Delete(ctx, sts.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return pruned, fmt.Errorf("delete statefulset/%s: %w", sts.Name, err)
+		}
+		pruned = append(pruned, sts.Name)
+	}
+	return pruned, nil
+}
+
+// keysSecretName is the Secret with the keys of the pods of a StatefulSet.
+func keysSecretName(workload string) string {
+	return workload + "-keys"
+}
+
+// pruneSecrets deletes the key Secrets of a topology not in keep, and the single
+// <svc>-secrets of earlier deploys that held the keys of every pod.
+func pruneSecrets(ctx context.Context, client kubernetes.Interface, svcName, namespace string, keep map[string]bool) ([]string, error) {
+	list, err := client.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=" + svcName})
+	if err != nil {
+		return nil, fmt.Errorf("list secrets: %w", err)
+	}
+	names := []string{svcName + "-secrets"}
+	for _, secret := range list.Items {
+		names = append(names, secret.Name)
+	}
+	var pruned []string
+	for _, name := range names {
+		if keep[name] {
+			continue
+		}
+		err := client.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return pruned, fmt.Errorf("delete secret/%s: %w", name, err)
+		}
+		pruned = append(pruned, name)
+	}
+	sort.Strings(pruned)
+	return pruned, nil
+}
+
+// configHash hashes the data of the ConfigMaps and Secrets mounted into the pods.
+func configHash(cms ...runtime.Object) string {
+	h := sha256.New()
+	for _, obj := range cms {
+		var data map[string][]byte
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			data = make(map[string][]byte, len(o.Data))
+			for k, v := range o.Data {
+				data[k] = []byte(v)
+			}
+		case *corev1.Secret:
+			data = o.Data
+		}
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s/%s=%x\n", obj.(metav1.Object).GetName(), k, sha256.Sum256(data[k]))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// objectRef formats an object as kind/name.
+func objectRef(obj runtime.Object) string {
+	name := ""
+	if m, ok := obj.(metav1.Object); ok {
+		name = m.GetName()
+	}
+	return strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind) + "/" + name
+}
+
+// applyOverrides adds a profile's selector label, extra env, pod labels and
+// annotations to its StatefulSet. The selector of the default StatefulSet
+// excludes the pods carrying WorkloadLabel, so the selectors of the
+// StatefulSets of a topology never overlap.
+func applyOverrides(sts *appsv1.StatefulSet, p *profile, svcName string) {
+	tmpl := &sts.Spec.Template
+	tmpl.Annotations = map[string]string{}
+	for k, v := range p.Overrides.Annotations {
+		tmpl.Annotations[k] = v
+	}
+	for k, v := range p.Overrides.Labels {
+		tmpl.Labels[k] = v
+	}
+	if p.Name != svcName {
+		tmpl.Labels[WorkloadLabel] = p.Name
+		sts.Spec.Selector.MatchLabels[WorkloadLabel] = p.Name
+	} else {
+		sts.Spec.Selector.MatchExpressions = []metav1.LabelSelectorRequirement{
+			{Key: WorkloadLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+		}
+	}
+	env := p.Overrides.envVars()
+	if len(env) == 0 {
+		return
+	}
+	for _, containers := range [][]corev1.Container{tmpl.Spec.InitContainers, tmpl.Spec.Containers} {
+		for i := range containers {
+			containers[i].Env = mergeEnv(containers[i].Env, env)
+		}
+	}
 }
 
-func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID string) *appsv1.StatefulSet {
+// forcePrivateNetwork makes kubo refuse to start without the swarm key the
+// configure script writes, so a node can never fall back to the public network.
+func forcePrivateNetwork(sts *appsv1.StatefulSet) {
+	containers := sts.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name == "ipfs" {
+			containers[i].Env = mergeEnv(containers[i].Env, []corev1.EnvVar{{Name: "LIBP2P_FORCE_PNET", Value: "1"}})
+		}
+	}
+}
+
+// mergeEnv appends extra to env, replacing variables of the same name.
+func mergeEnv(env, extra []corev1.EnvVar) []corev1.EnvVar {
+	out := make([]corev1.EnvVar, 0, len(env)+len(extra))
+	override := make(map[string]bool, len(extra))
+	for _, e := range extra {
+		override[e.Name] = true
+	}
+	for _, e := range env {
+		if !override[e.Name] {
+			out = append(out, e)
+		}
+	}
+	return append(out, extra...)
+}
+
+func buildStatefulSet(name, svcName, namespace string, replicas int32, w Workload) *appsv1.StatefulSet {
 	return &appsv1.StatefulSet{
-		ObjectMeta: metav1.ObjectMeta{Name: svcName, Namespace: namespace},
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": svcName},
+		},
 		Spec: appsv1.StatefulSetSpec{
 			ServiceName: svcName,
 			Replicas:    &replicas,
@@ -175,19 +595,23 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 					InitContainers: []corev1.Container{
 						{
 							Name:  "configure-ipfs",
-							Image: "ipfs/kubo:release",
+							Image: w.IPFSImage,
 							Command: []string{"sh", "/custom/configure-ipfs.sh"},
+							Resources: w.IPFSResources.requirements(),
 							VolumeMounts: []corev1.VolumeMount{
 								{Name: "ipfs-storage", MountPath: "/data/ipfs"},
 								{Name: "configure-script", MountPath: "/custom"},
+								{Name: "peer-env", MountPath: "/env"},
+								{Name: "peer-keys", MountPath: "/keys", ReadOnly: true},
 							},
 						},
 					},
 					Containers: []corev1.Container{
 						{
 							Name:  "ipfs",
-							Image: "ipfs/kubo:release",
+							Image: w.IPFSImage,
 							Env:   []corev1.EnvVar{{Name: "IPFS_FD_MAX", Value: "4096"}},
+							Resources: w.IPFSResources.requirements(),
 							Ports: []corev1.ContainerPort{
 								{Name: "swarm", ContainerPort: 4001, Protocol: corev1.ProtocolTCP},
 								{Name: "swarm-udp", ContainerPort: 4002, Protocol: corev1.ProtocolUDP},
@@ -202,28 +626,10 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 						},
 						{
 							Name:  "ipfs-cluster",
-							Image: "ipfs/ipfs-cluster:latest",
+							Image: w.ClusterImage,
 							Command: []string{"sh", "/custom/entrypoint.sh"},
+							Resources: w.ClusterResources.requirements(),
 							Env: []corev1.EnvVar{
-								{Name: "BOOTSTRAP_PEER_ID", ValueFrom: &corev1.EnvVarSource{
-									ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
-										LocalObjectReference: corev1.LocalObjectReference{Name: svcName + "-env"},
-										Key:                  "bootstrap-peer-id",
-									},
-								}},
-								{Name: "BOOTSTRAP_PEER_PRIV_KEY", ValueFrom: &corev1.EnvVarSource{
-									SecretKeyRef: &corev1.SecretKeySelector{
-										LocalObjectReference: corev1.LocalObjectReference{Name: svcName + "-secrets"},
-										Key:                  "bootstrap-peer-priv-key",
-									},
-								}},
-								{Name: "CLUSTER_SECRET", ValueFrom: &corev1.EnvVarSource{
-									SecretKeyRef: &corev1.SecretKeySelector{
-										LocalObjectReference: corev1.LocalObjectReference{Name: svcName + "-secrets"},
-										Key:                  "cluster-secret",
-									},
-								}},
-								{Name: "CLUSTER_MONITOR_PING_INTERVAL", Value: "3m"},
 								{Name: "SVC_NAME", Value: svcName},
 							},
 							Ports: []corev1.ContainerPort{
@@ -234,6 +640,9 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 							VolumeMounts: []corev1.VolumeMount{
 								{Name: "cluster-storage", MountPath: "/data/ipfs-cluster"},
 								{Name: "configure-script", MountPath: "/custom"},
+								{Name: "cluster-config", MountPath: "/cluster-config"},
+								{Name: "peer-env", MountPath: "/env"},
+								{Name: "peer-keys", MountPath: "/keys", ReadOnly: true},
 							},
 						},
 					},
@@ -246,6 +655,28 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 								},
 							},
 						},
+						{
+							Name: "cluster-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: svcName + "-cluster"},
+								},
+							},
+						},
+						{
+							Name: "peer-env",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: svcName + "-env"},
+								},
+							},
+						},
+						{
+							Name: "peer-keys",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: keysSecretName(name)},
+							},
+						},
 					},
 				},
 			},
@@ -254,10 +685,10 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 					ObjectMeta: metav1.ObjectMeta{Name: "cluster-storage"},
 					Spec: corev1.PersistentVolumeClaimSpec{
 						AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-						StorageClassName: strPtr("standard"),
+						StorageClassName: strPtr(w.StorageClass),
 						Resources: corev1.VolumeResourceRequirements{
 							Requests: corev1.ResourceList{
-								corev1.ResourceStorage: resource.MustParse("30Gi"),
+								corev1.ResourceStorage: resource.MustParse(w.ClusterStorageSize),
 							},
 						},
 					},
@@ -266,10 +697,10 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 					ObjectMeta: metav1.ObjectMeta{Name: "ipfs-storage"},
 					Spec: corev1.PersistentVolumeClaimSpec{
 						AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
-						StorageClassName: strPtr("standard"),
+						StorageClassName: strPtr(w.StorageClass),
 						Resources: corev1.VolumeResourceRequirements{
 							Requests: corev1.ResourceList{
-								corev1.ResourceStorage: resource.MustParse("30Gi"),
+								corev1.ResourceStorage: resource.MustParse(w.IPFSStorageSize),
 							},
 						},
 					},
@@ -281,27 +712,55 @@ func buildStatefulSet(svcName, namespace string, replicas int32, bootstrapPeerID
 
 func strPtr(s string) *string { return &s }
 
-func getEntrypointScript(svcName, bootstrapPeerID string) string {
+// getEntrypointScript starts ipfs-cluster with the generated service.json, the
+// pod's pre-generated identity and the secret of its network, and bootstraps it
+// to the peers listed in its env file (the canvas edge targets). Targets that
+// OrderedReady does not start first are waited for, up to two minutes each,
+// until their cluster API answers. identity.json is rewritten on every start,
+// so a volume that changed nodes carries the identity of its current node. The
+// daemon runs as a child of the shell, which forwards SIGTERM: as PID 1 it
+// could not be stopped by the pause-cluster chaos action.
+func getEntrypointScript() string {
 	return `#!/bin/sh
 user=ipfs
 sleep 10
-if [ ! -f /data/ipfs-cluster/service.json ]; then
-  ipfs-cluster-service init
-fi
-sed -i 's~/ip4/127.0.0.1/tcp/9095~/ip4/0.0.0.0/tcp/9095~g' /data/ipfs-cluster/service.json
-sed -i 's~/ip4/127.0.0.1/tcp/9094~/ip4/0.0.0.0/tcp/9094~g' /data/ipfs-cluster/service.json
+POD_NAME=$(cat /proc/sys/kernel/hostname)
+. /env/${POD_NAME}.env
+mkdir -p /data/ipfs-cluster
+cp /cluster-config/service.json /data/ipfs-cluster/service.json
+printf '{"id": "%s", "private_key": "%s"}\n' "${CLUSTER_PEER_ID}" "$(cat /keys/${POD_NAME}.cluster-priv-key)" > /data/ipfs-cluster/identity.json
 
-if echo $(cat /proc/sys/kernel/hostname) | grep -q "` + svcName + `-0"; then
-  CLUSTER_ID=${BOOTSTRAP_PEER_ID} \
-  CLUSTER_PRIVATEKEY=${BOOTSTRAP_PEER_PRIV_KEY} \
-  exec ipfs-cluster-service daemon --upgrade
+export CLUSTER_PEERNAME=${POD_NAME}
+export CLUSTER_ID=${CLUSTER_PEER_ID}
+export CLUSTER_PRIVATEKEY=$(cat /keys/${POD_NAME}.cluster-priv-key)
+export CLUSTER_SECRET=$(cat /keys/${CLUSTER_SECRET_KEY})
+for host in ${CLUSTER_WAIT_FOR}; do
+  i=0
+  until ipfs-cluster-ctl --host /dns4/${host}/tcp/9094 --timeout 5 id >/dev/null 2>&1 || [ ${i} -ge 12 ]; do
+    i=$((i+1))
+    sleep 5
+  done
+done
+if [ -n "${CLUSTER_BOOTSTRAP}" ]; then
+  ipfs-cluster-service daemon --upgrade --bootstrap ${CLUSTER_BOOTSTRAP} --leave &
 else
-  BOOTSTRAP_ADDR=/dns4/` + svcName + `-0.` + svcName + `/tcp/9096/ipfs/` + bootstrapPeerID + `
-  exec ipfs-cluster-service daemon --upgrade --bootstrap $BOOTSTRAP_ADDR --leave
+  ipfs-cluster-service daemon --upgrade &
 fi
+pid=$!
+trap 'kill -TERM ${pid} 2>/dev/null' TERM INT
+wait ${pid}
+status=$?
+while kill -0 ${pid} 2>/dev/null; do
+  wait ${pid}
+  status=$?
+done
+exit ${status}
 `
 }
 
+// getConfigureIPFSScript initialises the kubo repo once with the pod's init
+// profiles and on every start re-applies its identity, swarm key, config values
+// and peering, so config and edge changes take effect on restart.
 func getConfigureIPFSScript() string {
 	return `#!/bin/sh
 set -e
@@ -309,42 +768,66 @@ set -x
 user=root
 mkdir -p /data/ipfs && chown -R ipfs /data/ipfs
 user=ipfs
+POD_NAME=$(cat /proc/sys/kernel/hostname)
+. /env/${POD_NAME}.env
 if [ -f /data/ipfs/config ]; then
   if [ -f /data/ipfs/repo.lock ]; then
     rm /data/ipfs/repo.lock
   fi
-  exit 0
+else
+  ipfs init --profile="${IPFS_PROFILES}"
+  ipfs config Addresses.API /ip4/0.0.0.0/tcp/5001
+  ipfs config Addresses.Gateway /ip4/0.0.0.0/tcp/8080
 fi
-ipfs init --profile=badgerds,server
-ipfs config --json Addresses.API /ip4/0.0.0.0/tcp/5001
-ipfs config --json Addresses.Gateway /ip4/0.0.0.0/tcp/8080
-ipfs config --json Swarm.ConnMgr.HighWater 2000
-ipfs config --json Datastore.BloomFilterSize 1048576
-ipfs config Datastore.StorageMax 100GB
+set +x
+IPFS_PRIV_KEY=$(cat /keys/${POD_NAME}.ipfs-priv-key)
+sed -i "s~\"PeerID\": \"[^\"]*\"~\"PeerID\": \"${IPFS_PEER_ID}\"~" /data/ipfs/config
+sed -i "s~\"PrivKey\": \"[^\"]*\"~\"PrivKey\": \"${IPFS_PRIV_KEY}\"~" /data/ipfs/config
+set -x
+if [ -n "${SWARM_KEY_KEY}" ]; then
+  printf '/key/swarm/psk/1.0.0/\n/base16/\n%s\n' "$(cat /keys/${SWARM_KEY_KEY})" > /data/ipfs/swarm.key
+else
+  rm -f /data/ipfs/swarm.key
+fi
+. /env/${POD_NAME}.kubo-config
+ipfs config --json Peering.Peers "${IPFS_PEERING}"
 `
 }
 
 func Undeploy(ctx context.Context, client kubernetes.Interface, topologyID, namespace string) error {
-	svcName := "ipfs-" + strings.ReplaceAll(topologyID, "-", "")[:12]
+	svcName := ServiceName(topologyID)
 	propagation := metav1.DeletePropagationForeground
-	_ = client.AppsV1().StatefulSets(namespace).Delete(ctx, svcName, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	names := []string{svcName}
+	if list, err := listStatefulSets(ctx, client, topologyID, namespace); err == nil {
+		names = names[:0]
+		for _, sts := range list {
+			names = append(names, sts.Name)
+		}
+	}
+	for _, name := range names {
+		_ = client.AppsV1().StatefulSets(namespace).Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	}
 	_ = client.CoreV1().Services(namespace).Delete(ctx, svcName, metav1.DeleteOptions{})
 	_ = client.CoreV1().Services(namespace).Delete(ctx, svcName+"-external", metav1.DeleteOptions{})
 	_ = client.CoreV1().ConfigMaps(namespace).Delete(ctx, svcName+"-scripts", metav1.DeleteOptions{})
+	_ = client.CoreV1().ConfigMaps(namespace).Delete(ctx, svcName+"-cluster", metav1.DeleteOptions{})
 	_ = client.CoreV1().ConfigMaps(namespace).Delete(ctx, svcName+"-env", metav1.DeleteOptions{})
 	_ = client.CoreV1().Secrets(namespace).Delete(ctx, svcName+"-secrets", metav1.DeleteOptions{})
+	_ = client.CoreV1().Secrets(namespace).DeleteCollection(ctx, metav1.DeleteOptions{},
+		metav1.ListOptions{LabelSelector: "app=" + svcName})
+	_ = client.NetworkingV1().NetworkPolicies(namespace).DeleteCollection(ctx, metav1.DeleteOptions{},
+		metav1.ListOptions{LabelSelector: "app=" + svcName})
 	return nil
 }
 
 type PodStatusResult struct {
-	NodeID  string
 	PodName string
 	Phase   string
 	Ready   bool
 }
 
 func GetPodsStatus(ctx context.Context, client kubernetes.Interface, topologyID, namespace string) ([]PodStatusResult, error) {
-	svcName := "ipfs-" + strings.ReplaceAll(topologyID, "-", "")[:12]
+	svcName := ServiceName(topologyID)
 	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: "app=" + svcName,
 	})
@@ -361,7 +844,6 @@ func GetPodsStatus(ctx context.Context, client kubernetes.Interface, topologyID,
 			}
 		}
 		result = append(result, PodStatusResult{
-			NodeID:  p.Labels["statefulset.kubernetes.io/pod-name"],
 			PodName: p.Name,
 			Phase:   string(p.Status.Phase),
 			Ready:   ready,
@@ -370,21 +852,26 @@ func GetPodsStatus(ctx context.Context, client kubernetes.Interface, topologyID,
 	return result, nil
 }
 
-// GetPodLogs returns logs for a pod's container. container can be "ipfs", "ipfs-cluster", or "" for first container.
-func GetPodLogs(ctx context.Context, client kubernetes.Interface, namespace, podName, container string) (string, error) {
-	opts := &corev1.PodLogOptions{}
-	if container != "" {
-		opts.Container = container
-	}
-	req := client.CoreV1().Pods(namespace).GetLogs(podName, opts)
-	stream, err := req.Stream(ctx)
-	if err != nil {
-		return "", err
-	}
-	defer stream.Close()
-	b, err := io.ReadAll(stream)
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
+// LogOptions selects which part of a container log to read.
+type LogOptions struct {
+	Container    string // "ipfs", "ipfs-cluster", or "" for the first container
+	Follow       bool
+	Previous     bool // logs of the previous, terminated container instance
+	Timestamps   bool
+	TailLines    *int64
+	SinceSeconds *int64
+}
+
+// GetPodLogs opens a stream of a pod container's logs. With Follow the stream
+// stays open until ctx is done or the container exits. The caller closes it.
+func GetPodLogs(ctx context.Context, client kubernetes.Interface, namespace, podName string, opts LogOptions) (io.ReadCloser, error) {
+	req := client.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container:    opts.Container,
+		Follow:       opts.Follow,
+		Previous:     opts.Previous,
+		Timestamps:   opts.Timestamps,
+		TailLines:    opts.TailLines,
+		SinceSeconds: opts.SinceSeconds,
+	})
+	return req.Stream(ctx)
 }
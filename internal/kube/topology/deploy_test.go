@@ -0,0 +1,204 @@
+package topology
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func buildTestObjects(t *testing.T, cfg DeployConfig) (*Objects, map[string]string) {
+	t.Helper()
+	objects, assignments, err := BuildObjects(cfg)
+	if err != nil {
+		t.Fatalf("BuildObjects() error = %v", err)
+	}
+	pods := make(map[string]string, len(assignments))
+	for _, a := range assignments {
+		pods[a.NodeID] = a.PodName
+	}
+	return objects, pods
+}
+
+func container(sts *appsv1.StatefulSet, name string) corev1.Container {
+	for _, c := range sts.Spec.Template.Spec.Containers {
+		if c.Name == name {
+			return c
+		}
+	}
+	return corev1.Container{}
+}
+
+func configMap(objects *Objects, name string) *corev1.ConfigMap {
+	for _, cm := range objects.ConfigMaps {
+		if cm.Name == name {
+			return cm
+		}
+	}
+	return &corev1.ConfigMap{}
+}
+
+func TestBuildObjectsProfiles(t *testing.T) {
+	cfg, _ := testDeployConfig(t)
+	objects, pods := buildTestObjects(t, cfg)
+	const svcName = "ipfs-0f3c2a6e9b1d"
+	const profile = svcName + "-n2e7d2c0" // sha256("c")
+
+	var names []string
+	byName := map[string]*appsv1.StatefulSet{}
+	for _, sts := range objects.StatefulSets {
+		names = append(names, sts.Name)
+		byName[sts.Name] = sts
+	}
+	if want := []string{svcName, profile}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("StatefulSets = %v, want %v", names, want)
+	}
+	for name, replicas := range map[string]int32{svcName: 2, profile: 1} {
+		if got := *byName[name].Spec.Replicas; got != replicas {
+			t.Errorf("%s replicas = %d, want %d", name, got, replicas)
+		}
+	}
+	if !isTopologyWorkload(svcName, profile) {
+		t.Errorf("isTopologyWorkload(%q) = false", profile)
+	}
+
+	// Every selector matches the pods of its own StatefulSet only.
+	for _, sts := range objects.StatefulSets {
+		selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+		if err != nil {
+			t.Fatalf("%s selector: %v", sts.Name, err)
+		}
+		for _, other := range objects.StatefulSets {
+			matches := selector.Matches(labels.Set(other.Spec.Template.Labels))
+			if matches != (other == sts) {
+				t.Errorf("selector of %s matches the pods of %s = %v", sts.Name, other.Name, matches)
+			}
+		}
+	}
+
+	tmpl := byName[profile].Spec.Template
+	if tmpl.Labels["tier"] != "edge" || tmpl.Labels[WorkloadLabel] != profile {
+		t.Errorf("profile pod labels = %v", tmpl.Labels)
+	}
+	if image := container(byName[profile], "ipfs").Image; !strings.HasSuffix(image, ":v0.30.0") {
+		t.Errorf("profile ipfs image = %q, want tag v0.30.0", image)
+	}
+	if image := container(byName[svcName], "ipfs").Image; strings.HasSuffix(image, ":v0.30.0") {
+		t.Errorf("default ipfs image = %q, want the workload tag", image)
+	}
+
+	// Every StatefulSet mounts a Secret with the keys of its own pods only.
+	wantKeys := map[string][]string{
+		keysSecretName(svcName): {pods["a"], pods["b"]},
+		keysSecretName(profile): {pods["c"]},
+	}
+	if len(objects.Secrets) != len(wantKeys) {
+		t.Fatalf("%d Secrets, want %d", len(objects.Secrets), len(wantKeys))
+	}
+	for _, secret := range objects.Secrets {
+		podNames, ok := wantKeys[secret.Name]
+		if !ok {
+			t.Errorf("unexpected Secret %s", secret.Name)
+			continue
+		}
+		want := []string{clusterSecretKey(0), swarmKeyKey(0)}
+		for _, pod := range podNames {
+			want = append(want, pod+".cluster-priv-key", pod+".ipfs-priv-key")
+		}
+		sort.Strings(want)
+		var got []string
+		for k := range secret.Data {
+			got = append(got, k)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s keys = %v, want %v", secret.Name, got, want)
+		}
+	}
+	for _, sts := range objects.StatefulSets {
+		var mounted []string
+		for _, v := range sts.Spec.Template.Spec.Volumes {
+			if v.Secret != nil {
+				mounted = append(mounted, v.Secret.SecretName)
+			}
+		}
+		if want := []string{keysSecretName(sts.Name)}; !reflect.DeepEqual(mounted, want) {
+			t.Errorf("%s mounts Secrets %v, want %v", sts.Name, mounted, want)
+		}
+	}
+}
+
+func TestBuildObjectsPrivateNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		private bool
+		want    map[string]string // kubo config values of node b
+	}{
+		{
+			name: "public network keeps the node config",
+			want: map[string]string{"Bootstrap": `["/dnsaddr/bootstrap.libp2p.io"]`, "Swarm.Transports.Network.QUIC": `true`},
+		},
+		{
+			name:    "private network overrides it",
+			private: true,
+			want:    map[string]string{"Bootstrap": `[]`, "Swarm.Transports.Network.QUIC": `false`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _ := testDeployConfig(t)
+			cfg.PrivateNetwork = tt.private
+			cfg.Nodes[1].Kubo = KuboConfig{Config: map[string]json.RawMessage{
+				"Bootstrap":                     json.RawMessage(`["/dnsaddr/bootstrap.libp2p.io"]`),
+				"Swarm.Transports.Network.QUIC": json.RawMessage(`true`),
+			}}
+			objects, pods := buildTestObjects(t, cfg)
+			envCM := configMap(objects, "ipfs-0f3c2a6e9b1d-env")
+
+			script := envCM.Data[pods["b"]+".kubo-config"]
+			for key, value := range tt.want {
+				if line := "ipfs config --json " + shellQuote(key) + " " + shellQuote(value); !strings.Contains(script, line) {
+					t.Errorf("kubo config of b lacks %q:\n%s", line, script)
+				}
+			}
+
+			wantSwarmKey := ""
+			if tt.private {
+				wantSwarmKey = swarmKeyKey(0)
+			}
+			for nodeID, pod := range pods {
+				env := envCM.Data[pod+".env"]
+				if line := "SWARM_KEY_KEY=" + shellQuote(wantSwarmKey); !strings.Contains(env, line) {
+					t.Errorf("env of %s lacks %s", nodeID, line)
+				}
+			}
+			for _, secret := range objects.Secrets {
+				if _, ok := secret.Data[swarmKeyKey(0)]; ok != tt.private {
+					t.Errorf("%s holds the swarm key = %v, want %v", secret.Name, ok, tt.private)
+				}
+			}
+			for _, sts := range objects.StatefulSets {
+				var forced bool
+				for _, e := range container(sts, "ipfs").Env {
+					forced = forced || e == (corev1.EnvVar{Name: "LIBP2P_FORCE_PNET", Value: "1"})
+				}
+				if forced != tt.private {
+					t.Errorf("%s sets LIBP2P_FORCE_PNET = %v, want %v", sts.Name, forced, tt.private)
+				}
+				for _, c := range sts.Spec.Template.Spec.Containers {
+					for _, e := range c.Env {
+						if c.Name != "ipfs" && e.Name == "LIBP2P_FORCE_PNET" {
+							t.Errorf("%s container %s sets LIBP2P_FORCE_PNET", sts.Name, c.Name)
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// claimTemplates are the volumeClaimTemplates of the topology StatefulSets; a
+// PVC is named <claim>-<pod>.
+var claimTemplates = []string{"ipfs-storage", "cluster-storage"}
+
+// Event is a core/v1 Event about a topology object. Repeats of the same event
+// are merged into one with the summed Count.
+type Event struct {
+	Kind      string
+	Name      string
+	PodName   string // the pod the object belongs to, for Pods and PVCs
+	Type      string // Normal | Warning
+	Reason    string
+	Message   string
+	Count     int32
+	FirstTime time.Time
+	LastTime  time.Time
+}
+
+// eventPageSize limits every events list request; larger results are paged.
+const eventPageSize = 500
+
+// eventListConcurrency bounds the events list requests in flight.
+const eventListConcurrency = 8
+
+// ListEvents returns the events of the topology StatefulSets, the given pods,
+// their PVCs and the Services, newest first. Events are selected by involved
+// object kind and name on the server, so other workloads sharing the namespace
+// are not read; neither are pods left out of the assignment, such as those of
+// nodes removed since the last deploy.
+func ListEvents(ctx context.Context, client kubernetes.Interface, topologyID, namespace string, pods []PodAssignment) ([]Event, error) {
+	svcName := ServiceName(topologyID)
+	selectors := []string{
+		eventSelector("Service", svcName),
+		eventSelector("Service", svcName+"-external"),
+		eventSelector("StatefulSet", svcName),
+	}
+	workloads := map[string]bool{svcName: true}
+	for _, p := range pods {
+		if !workloads[p.Workload] {
+			workloads[p.Workload] = true
+			selectors = append(selectors, eventSelector("StatefulSet", p.Workload))
+		}
+		selectors = append(selectors, eventSelector("Pod", p.PodName))
+		for _, claim := range claimTemplates {
+			selectors = append(selectors, eventSelector("PersistentVolumeClaim", claim+"-"+p.PodName))
+		}
+	}
+
+	pages := make([][]corev1.Event, len(selectors))
+	errs := make([]error, len(selectors))
+	sem := make(chan struct{}, eventListConcurrency)
+	var wg sync.WaitGroup
+	for i, selector := range selectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			pages[i], errs[i] = listEvents(ctx, client, namespace, selector)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var items []corev1.Event
+	for _, page := range pages {
+		items = append(items, page...)
+	}
+
+	byKey := map[string]*Event{}
+	for i := range items {
+		ev := &items[i]
+		podName, ok := eventObjectPod(svcName, ev.InvolvedObject.Kind, ev.InvolvedObject.Name)
+		if !ok {
+			continue
+		}
+		first, last, count := eventTimes(ev)
+		key := strings.Join([]string{ev.InvolvedObject.Kind, ev.InvolvedObject.Name, ev.Type, ev.Reason, ev.Message}, "\x00")
+		if e, ok := byKey[key]; ok {
+			e.Count += count
+			if first.Before(e.FirstTime) {
+				e.FirstTime = first
+			}
+			if last.After(e.LastTime) {
+				e.LastTime = last
+			}
+			continue
+		}
+		byKey[key] = &Event{
+			Kind:      ev.InvolvedObject.Kind,
+			Name:      ev.InvolvedObject.Name,
+			PodName:   podName,
+			Type:      ev.Type,
+			Reason:    ev.Reason,
+			Message:   ev.Message,
+			Count:     count,
+			FirstTime: first,
+			LastTime:  last,
+		}
+	}
+
+	events := make([]Event, 0, len(byKey))
+	for _, e := range byKey {
+		events = append(events, *e)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].LastTime.Equal(events[j].LastTime) {
+			return events[i].LastTime.After(events[j].LastTime)
+		}
+		if events[i].Kind != events[j].Kind {
+			return events[i].Kind < events[j].Kind
+		}
+		if events[i].Name != events[j].Name {
+			return events[i].Name < events[j].Name
+		}
+		return events[i].Reason < events[j].Reason
+	})
+	return events, nil
+}
+
+func eventSelector(kind, name string) string {
+	return "involvedObject.kind=" + kind + ",involvedObject.name=" + name
+}
+
+// listEvents returns the events matching a field selector, page by page.
+func listEvents(ctx context.Context, client kubernetes.Interface, namespace, fieldSelector string) ([]corev1.Event, error) {
+	var out []corev1.Event
+	opts := metav1.ListOptions{FieldSelector: fieldSelector, Limit: eventPageSize}
+	for {
+		list, err := client.CoreV1().Events(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, list.Items...)
+		if list.Continue == "" {
+			return out, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+// eventObjectPod reports whether an involved object belongs to the topology and,
+// for pods and PVCs, which pod it is.
+func eventObjectPod(svcName, kind, name string) (string, bool) {
+	switch kind {
+	case "StatefulSet":
+		return "", isTopologyWorkload(svcName, name)
+	case "Service":
+		return "", name == svcName || name == svcName+"-external"
+	case "Pod":
+		return name, isTopologyPod(svcName, name)
+	case "PersistentVolumeClaim":
+		for _, claim := range claimTemplates {
+			if pod, ok := strings.CutPrefix(name, claim+"-"); ok && isTopologyPod(svcName, pod) {
+				return pod, true
+			}
+		}
+	}
+	return "", false
+}
+
+// isTopologyPod matches StatefulSet pod names <svc>-<ordinal> and, for
+// profiles, <svc>-n<hash>-<ordinal>.
+func isTopologyPod(svcName, name string) bool {
+	rest, ok := strings.CutPrefix(name, svcName+"-")
+	if !ok {
+		return false
+	}
+	i := strings.LastIndexByte(rest, '-') + 1
+	ordinal := rest[i:]
+	if ordinal == "" {
+		return false
+	}
+	for _, c := range ordinal {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// eventTimes reads the time span and repeat count from whichever of the legacy
+// and events.k8s.io fields the reporter filled in.
+func eventTimes(ev *corev1.Event) (time.Time, time.Time, int32) {
+	first, last := ev.FirstTimestamp.Time, ev.LastTimestamp.Time
+	count := ev.Count
+	if ev.Series != nil {
+		count = ev.Series.Count
+		if !ev.Series.LastObservedTime.IsZero() {
+			last = ev.Series.LastObservedTime.Time
+		}
+	}
+	if first.IsZero() {
+		first = ev.EventTime.Time
+	}
+	if first.IsZero() {
+		first = ev.CreationTimestamp.Time
+	}
+	if last.IsZero() {
+		last = first
+	}
+	if count < 1 {
+		count = 1
+	}
+	return first, last, count
+}
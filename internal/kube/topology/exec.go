@@ -0,0 +1,42 @@
+package topology
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// execInPod runs a command in a container of a pod and returns its stdout. A
+// non-zero exit is an error carrying the command's stderr.
+func execInPod(ctx context.Context, client kubernetes.Interface, restCfg *rest.Config, namespace, podName, container string, command []string) (string, error) {
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(restCfg, "POST", req.URL())
+	if err != nil {
+		return "", fmt.Errorf("exec in pod %s: %w", podName, err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("exec in pod %s: %w: %s", podName, err, msg)
+		}
+		return "", fmt.Errorf("exec in pod %s: %w", podName, err)
+	}
+	return stdout.String(), nil
+}
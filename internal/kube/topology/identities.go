@@ -0,0 +1,269 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"ipfs-visualizer/internal/kube"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	identitiesKey     = "identities.json"
+	clusterSecretsKey = "cluster-secrets.json"
+	swarmKeysKey      = "swarm-keys.json"
+)
+
+// NodeIdentity holds the libp2p identities of a canvas node: one for its
+// ipfs-cluster peer and one for its kubo peer.
+type NodeIdentity struct {
+	ClusterKey *kube.BootstrapKeyPair
+	IPFSKey    *kube.BootstrapKeyPair
+}
+
+type storedIdentity struct {
+	ClusterPrivKey string `json:"clusterPrivKey"`
+	IPFSPrivKey    string `json:"ipfsPrivKey"`
+}
+
+// IdentitySecretName is the Secret that keeps the private keys of a topology's nodes.
+// It lives outside the deploy namespace so identities survive undeploy.
+func IdentitySecretName(topologyID string) string {
+	return ServiceName(topologyID) + "-identities"
+}
+
+// EnsureIdentities makes sure every node has a persisted identity: missing ones are
+// generated, identities of removed nodes are dropped. It returns the identities of nodeIDs.
+func EnsureIdentities(ctx context.Context, client kubernetes.Interface, namespace, topologyID string, nodeIDs []string) (map[string]NodeIdentity, error) {
+	secret, exists, err := getIdentitySecret(ctx, client, namespace, topologyID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := storedIdentities(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+	result := make(map[string]NodeIdentity, len(nodeIDs))
+	keep := make(map[string]storedIdentity, len(nodeIDs))
+	for _, id := range nodeIDs {
+		s, ok := stored[id]
+		if !ok {
+			clusterKey, err := kube.GenerateBootstrapPrivateKey()
+			if err != nil {
+				return nil, fmt.Errorf("generate cluster key for node %s: %w", id, err)
+			}
+			ipfsKey, err := kube.GenerateBootstrapPrivateKey()
+			if err != nil {
+				return nil, fmt.Errorf("generate ipfs key for node %s: %w", id, err)
+			}
+			s = storedIdentity{ClusterPrivKey: clusterKey.PrivateKey, IPFSPrivKey: ipfsKey.PrivateKey}
+			changed = true
+		}
+		identity, err := decodeIdentity(s)
+		if err != nil {
+			return nil, fmt.Errorf("decode identity of node %s: %w", id, err)
+		}
+		keep[id] = s
+		result[id] = identity
+	}
+	if len(keep) != len(stored) {
+		changed = true
+	}
+	if !changed {
+		return result, nil
+	}
+
+	raw, err := json.Marshal(keep)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[identitiesKey] = raw
+	if exists {
+		_, err = client.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	} else {
+		_, err = client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("save identity secret: %w", err)
+	}
+	return result, nil
+}
+
+// EnsureClusterSecrets returns n persisted ipfs-cluster network secrets, generating
+// the missing ones, so a redeploy keeps the secret running peers were started with.
+// They are kept in the identity Secret next to the node keys.
+func EnsureClusterSecrets(ctx context.Context, client kubernetes.Interface, namespace, topologyID string, n int) ([]string, error) {
+	return ensureNetworkSecrets(ctx, client, namespace, topologyID, clusterSecretsKey, n)
+}
+
+// EnsureSwarmKeys returns n persisted kubo private network keys, one per network,
+// generating the missing ones. They are kept in the identity Secret like the
+// cluster secrets.
+func EnsureSwarmKeys(ctx context.Context, client kubernetes.Interface, namespace, topologyID string, n int) ([]string, error) {
+	return ensureNetworkSecrets(ctx, client, namespace, topologyID, swarmKeysKey, n)
+}
+
+// ensureNetworkSecrets returns n 32-byte hex secrets stored as a JSON list under
+// key of the identity Secret, appending generated ones when there are fewer.
+func ensureNetworkSecrets(ctx context.Context, client kubernetes.Interface, namespace, topologyID, key string, n int) ([]string, error) {
+	secret, exists, err := getIdentitySecret(ctx, client, namespace, topologyID)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := storedNetworkSecrets(secret, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) >= n {
+		return secrets[:n], nil
+	}
+	for len(secrets) < n {
+		s, err := kube.GenerateClusterSecret()
+		if err != nil {
+			return nil, fmt.Errorf("generate secret: %w", err)
+		}
+		secrets = append(secrets, s)
+	}
+
+	raw, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[key] = raw
+	if exists {
+		_, err = client.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	} else {
+		_, err = client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("save identity secret: %w", err)
+	}
+	return secrets, nil
+}
+
+// LoadIdentities returns the persisted identities of nodeIDs without changing
+// the identity Secret; nodes without one are left out.
+func LoadIdentities(ctx context.Context, client kubernetes.Interface, namespace, topologyID string, nodeIDs []string) (map[string]NodeIdentity, error) {
+	secret, _, err := getIdentitySecret(ctx, client, namespace, topologyID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := storedIdentities(secret)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]NodeIdentity, len(nodeIDs))
+	for _, id := range nodeIDs {
+		s, ok := stored[id]
+		if !ok {
+			continue
+		}
+		identity, err := decodeIdentity(s)
+		if err != nil {
+			return nil, fmt.Errorf("decode identity of node %s: %w", id, err)
+		}
+		result[id] = identity
+	}
+	return result, nil
+}
+
+// LoadClusterSecrets returns up to n persisted ipfs-cluster network secrets
+// without generating the missing ones.
+func LoadClusterSecrets(ctx context.Context, client kubernetes.Interface, namespace, topologyID string, n int) ([]string, error) {
+	return loadNetworkSecrets(ctx, client, namespace, topologyID, clusterSecretsKey, n)
+}
+
+// LoadSwarmKeys returns up to n persisted swarm keys without generating the
+// missing ones.
+func LoadSwarmKeys(ctx context.Context, client kubernetes.Interface, namespace, topologyID string, n int) ([]string, error) {
+	return loadNetworkSecrets(ctx, client, namespace, topologyID, swarmKeysKey, n)
+}
+
+func loadNetworkSecrets(ctx context.Context, client kubernetes.Interface, namespace, topologyID, key string, n int) ([]string, error) {
+	secret, _, err := getIdentitySecret(ctx, client, namespace, topologyID)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := storedNetworkSecrets(secret, key)
+	if err != nil {
+		return nil, err
+	}
+	return secrets[:min(len(secrets), n)], nil
+}
+
+// getIdentitySecret returns the identity Secret of a topology and whether it
+// exists; a missing one is returned empty, ready to be created.
+func getIdentitySecret(ctx context.Context, client kubernetes.Interface, namespace, topologyID string) (*corev1.Secret, bool, error) {
+	name := IdentitySecretName(topologyID)
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return secret, true, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, false, fmt.Errorf("get identity secret: %w", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"ipfs-visualizer/topology-id": topologyID},
+		},
+		Type: corev1.SecretTypeOpaque,
+	}, false, nil
+}
+
+func storedIdentities(secret *corev1.Secret) (map[string]storedIdentity, error) {
+	stored := map[string]storedIdentity{}
+	if raw := secret.Data[identitiesKey]; len(raw) > 0 {
+		if err := json.Unmarshal(raw, &stored); err != nil {
+			return nil, fmt.Errorf("decode identity secret: %w", err)
+		}
+	}
+	return stored, nil
+}
+
+func storedNetworkSecrets(secret *corev1.Secret, key string) ([]string, error) {
+	var secrets []string
+	if raw := secret.Data[key]; len(raw) > 0 {
+		if err := json.Unmarshal(raw, &secrets); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", key, err)
+		}
+	}
+	return secrets, nil
+}
+
+// DeleteIdentities removes the identity Secret of a topology.
+func DeleteIdentities(ctx context.Context, client kubernetes.Interface, namespace, topologyID string) error {
+	err := client.CoreV1().Secrets(namespace).Delete(ctx, IdentitySecretName(topologyID), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func decodeIdentity(s storedIdentity) (NodeIdentity, error) {
+	clusterKey, err := kube.KeyPairFromPrivateKey(s.ClusterPrivKey)
+	if err != nil {
+		return NodeIdentity{}, err
+	}
+	ipfsKey, err := kube.KeyPairFromPrivateKey(s.IPFSPrivKey)
+	if err != nil {
+		return NodeIdentity{}, err
+	}
+	return NodeIdentity{ClusterKey: clusterKey, IPFSKey: ipfsKey}, nil
+}
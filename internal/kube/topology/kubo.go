@@ -0,0 +1,119 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// SwarmPeer is one open libp2p connection of a kubo node.
+type SwarmPeer struct {
+	Peer string `json:"Peer"`
+	Addr string `json:"Addr"`
+}
+
+// kuboRPC calls the kubo RPC API (/api/v0, POST only) of a pod and decodes the
+// JSON answer into out.
+func kuboRPC(ctx context.Context, client kubernetes.Interface, namespace, podName, command string, args url.Values, out any) error {
+	req := ProxyRequest{Method: http.MethodPost, Path: "/api/v0/" + command, Query: args}
+	return proxyJSON(ctx, client, namespace, podName, PortIPFSAPI, req, out)
+}
+
+// GetSwarmPeers lists the libp2p connections of the kubo node in a pod.
+func GetSwarmPeers(ctx context.Context, client kubernetes.Interface, namespace, podName string) ([]SwarmPeer, error) {
+	var resp struct {
+		Peers []SwarmPeer `json:"Peers"`
+	}
+	if err := kuboRPC(ctx, client, namespace, podName, "swarm/peers", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Peers, nil
+}
+
+// GetPinType returns how a CID is pinned in the kubo node of a pod (recursive,
+// direct, indirect) or "" if it is not pinned.
+func GetPinType(ctx context.Context, client kubernetes.Interface, namespace, podName, cid string) (string, error) {
+	var resp struct {
+		Keys map[string]struct {
+			Type string `json:"Type"`
+		} `json:"Keys"`
+	}
+	args := url.Values{"arg": {cid}, "type": {"all"}}
+	err := kuboRPC(ctx, client, namespace, podName, "pin/ls", args, &resp)
+	if isKuboNotFound(err, "is not pinned") {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, k := range resp.Keys {
+		return k.Type, nil
+	}
+	return "", nil
+}
+
+// GetLocalBlockSize reports whether the block of a CID is in the kubo node's
+// local store, and its size. It never fetches from the network.
+func GetLocalBlockSize(ctx context.Context, client kubernetes.Interface, namespace, podName, cid string) (int64, bool, error) {
+	var resp struct {
+		Size int64 `json:"Size"`
+	}
+	args := url.Values{"arg": {cid}, "offline": {"true"}}
+	err := kuboRPC(ctx, client, namespace, podName, "block/stat", args, &resp)
+	if isKuboNotFound(err, "not found") {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return resp.Size, true, nil
+}
+
+// CountLocalRefs walks the DAG below a CID in the kubo node's local store. It
+// returns the number of distinct blocks found and whether none were missing.
+func CountLocalRefs(ctx context.Context, client kubernetes.Interface, namespace, podName, cid string) (int, bool, error) {
+	args := url.Values{"arg": {cid}, "recursive": {"true"}, "unique": {"true"}, "offline": {"true"}}
+	resp, err := ProxyPod(ctx, client, namespace, podName, PortIPFSAPI, ProxyRequest{Method: http.MethodPost, Path: "/api/v0/refs", Query: args})
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	if err := proxyError(resp); err != nil {
+		return 0, false, err
+	}
+
+	count, complete := 0, true
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ref struct {
+			Ref string `json:"Ref"`
+			Err string `json:"Err"`
+		}
+		if err := dec.Decode(&ref); err == io.EOF {
+			break
+		} else if err != nil {
+			return count, false, err
+		}
+		if ref.Err != "" {
+			complete = false
+			continue
+		}
+		count++
+	}
+	if resp.Trailer.Get("X-Stream-Error") != "" {
+		complete = false
+	}
+	return count, complete, nil
+}
+
+// isKuboNotFound matches the error kubo answers with when the object is absent.
+func isKuboNotFound(err error, substr string) bool {
+	var pe *ProxyError
+	return errors.As(err, &pe) && pe.StatusCode == http.StatusInternalServerError && strings.Contains(pe.Message, substr)
+}
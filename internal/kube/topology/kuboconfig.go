@@ -0,0 +1,151 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kubo init profiles accepted by ipfs init --profile.
+var kuboProfiles = map[string]bool{
+	"server": true, "randomports": true, "local-discovery": true, "default-networking": true,
+	"lowpower": true, "announce-off": true, "announce-on": true,
+	"flatfs": true, "flatfs-measure": true, "pebbleds": true, "pebbleds-measure": true,
+	"badgerds": true, "badgerds-measure": true, "default-datastore": true,
+	"legacy-cid-v0": true, "test-cid-v1": true, "test-cid-v1-wide": true, "test": true,
+}
+
+// Top-level sections of the kubo config a key may address.
+var kuboConfigSections = map[string]bool{
+	"API": true, "Addresses": true, "AutoNAT": true, "AutoTLS": true, "Bootstrap": true,
+	"DNS": true, "Datastore": true, "Discovery": true, "Experimental": true, "Gateway": true,
+	"HTTPRetrieval": true, "Import": true, "Internal": true, "Ipns": true, "Migration": true,
+	"Mounts": true, "Pinning": true, "Plugins": true, "Provider": true, "Pubsub": true,
+	"Reprovider": true, "Routing": true, "Swarm": true,
+}
+
+// reservedKuboKeys are set by the deploy itself: the node identity, the peering
+// derived from the edges and the API and gateway addresses the proxy relies on.
+var reservedKuboKeys = []string{"Identity", "Peering", "Addresses.API", "Addresses.Gateway"}
+
+var kuboConfigKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// KuboConfig selects the init profiles of a kubo repo and config values set on
+// top of it. Profiles take effect when the repo is created; config values are
+// applied on every pod start.
+type KuboConfig struct {
+	Profiles []string
+	Config   map[string]json.RawMessage // dotted config key -> JSON value
+}
+
+// DefaultKuboConfig returns the profiles and values of a topology that sets none.
+func DefaultKuboConfig() KuboConfig {
+	return KuboConfig{
+		Profiles: []string{"badgerds", "server"},
+		Config: map[string]json.RawMessage{
+			"Swarm.ConnMgr.HighWater":   json.RawMessage(`2000`),
+			"Datastore.BloomFilterSize": json.RawMessage(`1048576`),
+			"Datastore.StorageMax":      json.RawMessage(`"100GB"`),
+		},
+	}
+}
+
+// Merge returns k with the profiles of o, if it sets any, and the config values
+// of o on top of its own.
+func (k KuboConfig) Merge(o KuboConfig) KuboConfig {
+	out := KuboConfig{Profiles: k.Profiles, Config: make(map[string]json.RawMessage, len(k.Config)+len(o.Config))}
+	if len(o.Profiles) > 0 {
+		out.Profiles = o.Profiles
+	}
+	for key, v := range k.Config {
+		out.Config[key] = v
+	}
+	for key, v := range o.Config {
+		out.Config[key] = v
+	}
+	return out
+}
+
+// Validate checks that the profiles are known, that at most one picks the
+// datastore and that the config keys are valid, not managed by the deploy and
+// hold JSON values.
+func (k KuboConfig) Validate() error {
+	datastores := 0
+	for _, p := range k.Profiles {
+		if !kuboProfiles[p] {
+			return fmt.Errorf("unknown kubo profile %q", p)
+		}
+		if isDatastoreProfile(p) {
+			datastores++
+		}
+	}
+	if datastores > 1 {
+		return fmt.Errorf("kubo profiles %s select more than one datastore", strings.Join(k.Profiles, ","))
+	}
+	for key, v := range k.Config {
+		if !kuboConfigKeyRe.MatchString(key) {
+			return fmt.Errorf("invalid kubo config key %q", key)
+		}
+		if section, _, _ := strings.Cut(key, "."); !kuboConfigSections[section] && !isReservedKuboKey(section) {
+			return fmt.Errorf("unknown kubo config section %q", section)
+		}
+		if isReservedKuboKey(key) {
+			return fmt.Errorf("kubo config key %q is managed by the deploy", key)
+		}
+		if !json.Valid(v) {
+			return fmt.Errorf("kubo config %s: value is not valid JSON", key)
+		}
+	}
+	return nil
+}
+
+// HasProfile reports whether the profile is selected.
+func (k KuboConfig) HasProfile(name string) bool {
+	return slices.Contains(k.Profiles, name)
+}
+
+func isDatastoreProfile(p string) bool {
+	name := strings.TrimSuffix(p, "-measure")
+	return name == "flatfs" || name == "pebbleds" || name == "badgerds" || name == "default-datastore"
+}
+
+// isReservedKuboKey matches a reserved key and everything below it, and the
+// parents of reserved keys, which would overwrite them.
+func isReservedKuboKey(key string) bool {
+	for _, r := range reservedKuboKeys {
+		if key == r || strings.HasPrefix(key, r+".") || strings.HasPrefix(r, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// privateNetworkKubo empties the public bootstrap list and turns off what
+// kubo refuses to run with a swarm key: the UDP transports and AutoTLS.
+func privateNetworkKubo() KuboConfig {
+	return KuboConfig{Config: map[string]json.RawMessage{
+		"Bootstrap":                             json.RawMessage(`[]`),
+		"Swarm.Transports.Network.QUIC":         json.RawMessage(`false`),
+		"Swarm.Transports.Network.WebTransport": json.RawMessage(`false`),
+		"Swarm.Transports.Network.WebRTCDirect": json.RawMessage(`false`),
+		"AutoTLS.Enabled":                       json.RawMessage(`false`),
+	}}
+}
+
+// kuboConfigScript renders the config values as ipfs config commands, sorted by
+// key, for the configure script to source.
+func kuboConfigScript(k KuboConfig) string {
+	keys := make([]string, 0, len(k.Config))
+	for key := range k.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString("ipfs config --json " + shellQuote(key) + " " + shellQuote(string(k.Config[key])) + "\n")
+	}
+	return b.String()
+}
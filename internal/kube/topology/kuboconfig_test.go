@@ -0,0 +1,67 @@
+package topology
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func kuboConfig(key, value string) KuboConfig {
+	return KuboConfig{Config: map[string]json.RawMessage{key: json.RawMessage(value)}}
+}
+
+func TestKuboConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		k       KuboConfig
+		wantErr bool
+	}{
+		{name: "defaults", k: DefaultKuboConfig()},
+		{name: "empty", k: KuboConfig{}},
+		{name: "one datastore with other profiles", k: KuboConfig{Profiles: []string{"pebbleds-measure", "server", "lowpower"}}},
+		{name: "unknown profile", k: KuboConfig{Profiles: []string{"server", "fast"}}, wantErr: true},
+		{name: "two datastores", k: KuboConfig{Profiles: []string{"flatfs", "badgerds-measure"}}, wantErr: true},
+		{name: "nested key", k: kuboConfig("Swarm.ResourceMgr.Enabled", `false`)},
+		{name: "address outside the reserved ones", k: kuboConfig("Addresses.Swarm", `["/ip4/0.0.0.0/tcp/4001"]`)},
+		{name: "empty key segment", k: kuboConfig("Swarm..ConnMgr", `1`), wantErr: true},
+		{name: "key with a space", k: kuboConfig("Swarm.Conn Mgr", `1`), wantErr: true},
+		{name: "unknown section", k: kuboConfig("Foo.Bar", `1`), wantErr: true},
+		{name: "reserved key", k: kuboConfig("Addresses.API", `"/ip4/0.0.0.0/tcp/5001"`), wantErr: true},
+		{name: "reserved section", k: kuboConfig("Identity", `{}`), wantErr: true},
+		{name: "below a reserved key", k: kuboConfig("Peering.Peers", `[]`), wantErr: true},
+		{name: "parent of a reserved key", k: kuboConfig("Addresses", `{}`), wantErr: true},
+		{name: "invalid JSON value", k: kuboConfig("Datastore.StorageMax", `100GB`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.k.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsReservedKuboKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"Identity", true},
+		{"Identity.PeerID", true},
+		{"Peering.Peers", true},
+		{"Addresses.API", true},
+		{"Addresses.Gateway", true},
+		{"Addresses", true},
+		{"Addresses.Swarm", false},
+		{"Addresses.APIs", false},
+		{"IdentityFile", false},
+		{"Gateway", false},
+		{"Swarm.ConnMgr.HighWater", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := isReservedKuboKey(tt.key); got != tt.want {
+				t.Errorf("isReservedKuboKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
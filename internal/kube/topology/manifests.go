@@ -0,0 +1,529 @@
+package topology
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"ipfs-visualizer/internal/kube"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	FormatYAML      = "yaml"
+	FormatHelm      = "helm"
+	FormatKustomize = "kustomize"
+)
+
+// Manifests is an exported topology: a YAML stream, or a gzipped tar archive
+// holding a Helm chart or a Kustomize base.
+type Manifests struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+type manifestFile struct {
+	Path string
+	Data []byte
+}
+
+var (
+	helmValueRe  = regexp.MustCompile(`['"]?HELMVALUE__([A-Za-z0-9.]+)__['"]?`)
+	helmSecretRe = regexp.MustCompile(`['"]?HELMSECRET__([A-Za-z0-9.-]+?)__['"]?`)
+	// Peer IDs are embedded in ConfigMap text, so their quotes are left alone.
+	helmPeerRe = regexp.MustCompile(`HELMPEER__([A-Za-z0-9.-]+?)__`)
+)
+
+// RenderManifests exports the objects Deploy would create. Secret values and the
+// peer IDs derived from the private keys are replaced by placeholders:
+// REPLACE_ME_* markers for yaml and kustomize, required chart values for helm.
+func RenderManifests(cfg DeployConfig, format string) (*Manifests, error) {
+	objects, _, err := BuildObjects(cfg)
+	if err != nil {
+		return nil, err
+	}
+	workload, err := cfg.Workload.resolve(cfg.Private)
+	if err != nil {
+		return nil, err
+	}
+	svcName := ServiceName(cfg.TopologyID)
+
+	switch format {
+	case "", FormatYAML:
+		data, err := renderYAML(objects)
+		if err != nil {
+			return nil, err
+		}
+		return &Manifests{FileName: svcName + ".yaml", ContentType: "application/yaml", Data: data}, nil
+	case FormatHelm:
+		files, err := renderHelmChart(svcName, cfg.Name, objects, workload)
+		if err != nil {
+			return nil, err
+		}
+		data, err := archive(svcName, files)
+		if err != nil {
+			return nil, err
+		}
+		return &Manifests{FileName: svcName + "-helm.tgz", ContentType: "application/gzip", Data: data}, nil
+	case FormatKustomize:
+		files, err := renderKustomize(cfg.Namespace, objects, workload)
+		if err != nil {
+			return nil, err
+		}
+		data, err := archive(svcName, files)
+		if err != nil {
+			return nil, err
+		}
+		return &Manifests{FileName: svcName + "-kustomize.tar.gz", ContentType: "application/gzip", Data: data}, nil
+	default:
+		return nil, fmt.Errorf("unknown manifest format %q (expected %s, %s or %s)", format, FormatYAML, FormatHelm, FormatKustomize)
+	}
+}
+
+func renderYAML(objects *Objects) ([]byte, error) {
+	if _, err := placeholderPeerIDs(objects, replaceMePlaceholder); err != nil {
+		return nil, err
+	}
+	placeholderSecrets(objects, replaceMePlaceholder)
+
+	var b bytes.Buffer
+	b.WriteString("# Secret values and peer IDs are REPLACE_ME_* placeholders, replace them before applying.\n")
+	for _, obj := range objects.List() {
+		doc, err := manifestDoc(obj, false)
+		if err != nil {
+			return nil, err
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString("---\n")
+		b.Write(out)
+	}
+	return b.Bytes(), nil
+}
+
+func renderHelmChart(svcName, topologyName string, objects *Objects, w Workload) ([]manifestFile, error) {
+	peerKeys, err := placeholderPeerIDs(objects, func(key string) string { return "HELMPEER__" + key + "__" })
+	if err != nil {
+		return nil, err
+	}
+	secretKeys := placeholderSecrets(objects, func(key string) string { return "HELMSECRET__" + key + "__" })
+
+	chart, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion":  "v2",
+		"name":        svcName,
+		"description": fmt.Sprintf("IPFS cluster topology %q", topologyName),
+		"type":        "application",
+		"version":     "0.1.0",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make(map[string]string, len(secretKeys))
+	for _, k := range secretKeys {
+		secrets[k] = ""
+	}
+	peerIDs := make(map[string]string, len(peerKeys))
+	for _, k := range peerKeys {
+		peerIDs[k] = ""
+	}
+	values, err := yaml.Marshal(map[string]interface{}{
+		"image": map[string]string{"ipfs": w.IPFSImage, "cluster": w.ClusterImage},
+		"storage": map[string]string{
+			"className":   w.StorageClass,
+			"ipfsSize":    w.IPFSStorageSize,
+			"clusterSize": w.ClusterStorageSize,
+		},
+		"service": map[string]string{"type": string(w.ServiceType)},
+		"resources": map[string]interface{}{
+			"ipfs":    helmResources(w.IPFSResources),
+			"cluster": helmResources(w.ClusterResources),
+		},
+		"secrets": secrets,
+		"peerIds": peerIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	values = append([]byte("# secrets must be set on install: cluster-secret-* and swarm-key-* are 32-byte hex strings, *-priv-key are\n"+
+		"# base64 libp2p private keys. peerIds must be set too: <pod>.*-peer-id is the peer ID of <pod>.*-priv-key.\n"), values...)
+
+	files := []manifestFile{{Path: "Chart.yaml", Data: chart}, {Path: "values.yaml", Data: values}}
+	for _, obj := range objects.List() {
+		doc, err := manifestDoc(obj, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := templateHelmValues(obj, doc, svcName, w); err != nil {
+			return nil, err
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		text := strings.ReplaceAll(string(out), "{{", `{{"{{"}}`)
+		text = helmValueRe.ReplaceAllString(text, `{{ .Values.$1 | quote }}`)
+		text = helmSecretRe.ReplaceAllString(text, `{{ required "secrets.$1 is required" (index .Values.secrets "$1") | quote }}`)
+		text = helmPeerRe.ReplaceAllString(text, `{{ required "peerIds.$1 is required" (index .Values.peerIds "$1") }}`)
+		files = append(files, manifestFile{Path: "templates/" + manifestFileName(obj), Data: []byte(text)})
+	}
+	return files, nil
+}
+
+// templateHelmValues swaps the parameterised fields of a rendered object for
+// chart value references. Fields a node profile overrides keep their value.
+func templateHelmValues(obj runtime.Object, doc map[string]interface{}, svcName string, w Workload) error {
+	switch o := obj.(type) {
+	case *corev1.Service:
+		if o.Name == svcName+"-external" {
+			return unstructured.SetNestedField(doc, "HELMVALUE__service.type__", "spec", "type")
+		}
+	case *appsv1.StatefulSet:
+		for _, field := range []string{"initContainers", "containers"} {
+			containers, _, err := unstructured.NestedSlice(doc, "spec", "template", "spec", field)
+			if err != nil {
+				return err
+			}
+			for _, item := range containers {
+				c, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				key, image, res := "ipfs", w.IPFSImage, w.IPFSResources
+				if c["name"] == "ipfs-cluster" {
+					key, image, res = "cluster", w.ClusterImage, w.ClusterResources
+				}
+				if c["image"] == image {
+					c["image"] = "HELMVALUE__image." + key + "__"
+				}
+				templateHelmResources(c, key, res)
+			}
+			if err := unstructured.SetNestedSlice(doc, containers, "spec", "template", "spec", field); err != nil {
+				return err
+			}
+		}
+
+		claims, _, err := unstructured.NestedSlice(doc, "spec", "volumeClaimTemplates")
+		if err != nil {
+			return err
+		}
+		for _, item := range claims {
+			claim, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			size, value := "HELMVALUE__storage.ipfsSize__", w.IPFSStorageSize
+			if name, _, _ := unstructured.NestedString(claim, "metadata", "name"); name == "cluster-storage" {
+				size, value = "HELMVALUE__storage.clusterSize__", w.ClusterStorageSize
+			}
+			if err := unstructured.SetNestedField(claim, "HELMVALUE__storage.className__", "spec", "storageClassName"); err != nil {
+				return err
+			}
+			if current, _, _ := unstructured.NestedString(claim, "spec", "resources", "requests", "storage"); !sameQuantity(current, value) {
+				continue
+			}
+			if err := unstructured.SetNestedField(claim, size, "spec", "resources", "requests", "storage"); err != nil {
+				return err
+			}
+		}
+		return unstructured.SetNestedSlice(doc, claims, "spec", "volumeClaimTemplates")
+	}
+	return nil
+}
+
+// helmResources lists the requests and limits set in r as chart values.
+func helmResources(r Resources) map[string]map[string]string {
+	out := map[string]map[string]string{}
+	for kind, values := range map[string]map[string]string{
+		"requests": {"cpu": r.CPURequest, "memory": r.MemoryRequest},
+		"limits":   {"cpu": r.CPULimit, "memory": r.MemoryLimit},
+	} {
+		for name, v := range values {
+			if v == "" {
+				continue
+			}
+			if out[kind] == nil {
+				out[kind] = map[string]string{}
+			}
+			out[kind][name] = v
+		}
+	}
+	return out
+}
+
+// templateHelmResources swaps the requests and limits of a rendered container
+// that match the workload r for resources.<key>.* chart values.
+func templateHelmResources(c map[string]interface{}, key string, r Resources) {
+	res, ok := c["resources"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	values := helmResources(r)
+	for _, kind := range []string{"requests", "limits"} {
+		list, ok := res[kind].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, v := range list {
+			if s, _ := v.(string); sameQuantity(s, values[kind][name]) {
+				list[name] = "HELMVALUE__resources." + key + "." + kind + "." + name + "__"
+			}
+		}
+	}
+}
+
+// sameQuantity reports whether two quantities are set and equal.
+func sameQuantity(a, b string) bool {
+	qa, errA := resource.ParseQuantity(a)
+	qb, errB := resource.ParseQuantity(b)
+	return errA == nil && errB == nil && qa.Cmp(qb) == 0
+}
+
+func renderKustomize(namespace string, objects *Objects, w Workload) ([]manifestFile, error) {
+	if _, err := placeholderPeerIDs(objects, replaceMePlaceholder); err != nil {
+		return nil, err
+	}
+	placeholderSecrets(objects, replaceMePlaceholder)
+
+	var files []manifestFile
+	resources := make([]string, 0, len(objects.List()))
+	for _, obj := range objects.List() {
+		doc, err := manifestDoc(obj, true)
+		if err != nil {
+			return nil, err
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		name := manifestFileName(obj)
+		resources = append(resources, name)
+		files = append(files, manifestFile{Path: name, Data: out})
+	}
+
+	var patches []map[string]interface{}
+	for _, sts := range objects.StatefulSets {
+		var ops []map[string]interface{}
+		for i, claim := range sts.Spec.VolumeClaimTemplates {
+			size := claim.Spec.Resources.Requests.Storage().String()
+			path := fmt.Sprintf("/spec/volumeClaimTemplates/%d/spec", i)
+			ops = append(ops,
+				map[string]interface{}{"op": "replace", "path": path + "/storageClassName", "value": w.StorageClass},
+				map[string]interface{}{"op": "replace", "path": path + "/resources/requests/storage", "value": size},
+			)
+		}
+		patch, err := jsonPatch("StatefulSet", sts.Name, ops)
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+	for _, svc := range objects.Services {
+		if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			continue
+		}
+		patch, err := jsonPatch("Service", svc.Name, []map[string]interface{}{
+			{"op": "replace", "path": "/spec/type", "value": string(w.ServiceType)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, patch)
+	}
+
+	// An images entry matches by name and would reset the tags profiles override,
+	// so images used with several tags are left as rendered.
+	tags := map[string]map[string]bool{}
+	for _, sts := range objects.StatefulSets {
+		for _, c := range append(append([]corev1.Container{}, sts.Spec.Template.Spec.InitContainers...), sts.Spec.Template.Spec.Containers...) {
+			name := kustomizeImage(c.Image)["name"]
+			if tags[name] == nil {
+				tags[name] = map[string]bool{}
+			}
+			tags[name][c.Image] = true
+		}
+	}
+	var images []map[string]string
+	for _, image := range []string{w.IPFSImage, w.ClusterImage} {
+		entry := kustomizeImage(image)
+		if len(tags[entry["name"]]) > 1 {
+			continue
+		}
+		images = append(images, entry)
+	}
+
+	kustomization, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"namespace":  namespace,
+		"resources":  resources,
+		"images":     images,
+		"patches":    patches,
+	})
+	if err != nil {
+		return nil, err
+	}
+	kustomization = append([]byte("# Secret values and peer IDs are REPLACE_ME_* placeholders, replace them before applying.\n"), kustomization...)
+	return append([]manifestFile{{Path: "kustomization.yaml", Data: kustomization}}, files...), nil
+}
+
+func jsonPatch(kind, name string, ops []map[string]interface{}) (map[string]interface{}, error) {
+	patch, err := yaml.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"target": map[string]string{"kind": kind, "name": name},
+		"patch":  string(patch),
+	}, nil
+}
+
+// kustomizeImage splits an image reference into a Kustomize images entry.
+func kustomizeImage(image string) map[string]string {
+	entry := map[string]string{"name": image}
+	if i := strings.Index(image, "@"); i >= 0 {
+		entry["name"], entry["digest"] = image[:i], image[i+1:]
+	} else if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		entry["name"], entry["newTag"] = image[:i], image[i+1:]
+	}
+	entry["newName"] = entry["name"]
+	return entry
+}
+
+// placeholderSecrets replaces every secret value with a placeholder and returns the keys.
+func placeholderSecrets(objects *Objects, placeholder func(key string) string) []string {
+	var keys []string
+	for _, s := range objects.Secrets {
+		s.StringData = make(map[string]string, len(s.Data))
+		for k := range s.Data {
+			s.StringData[k] = placeholder(k)
+			keys = append(keys, k)
+		}
+		s.Data = nil
+	}
+	return keys
+}
+
+// placeholderPeerIDs replaces the peer IDs of the private keys in the Secrets
+// wherever they appear in the ConfigMaps (env files, bootstrap addresses, trusted
+// peers) with a placeholder for <pod>.<kind>-peer-id, and returns those keys.
+// Exported peer IDs would otherwise pin the identities the placeholders hide.
+func placeholderPeerIDs(objects *Objects, placeholder func(key string) string) ([]string, error) {
+	var keys, replace []string
+	for _, s := range objects.Secrets {
+		for k, v := range s.Data {
+			prefix, ok := strings.CutSuffix(k, "-priv-key")
+			if !ok {
+				continue
+			}
+			pair, err := kube.KeyPairFromPrivateKey(string(v))
+			if err != nil {
+				return nil, fmt.Errorf("decode %s: %w", k, err)
+			}
+			key := prefix + "-peer-id"
+			keys = append(keys, key)
+			replace = append(replace, pair.PeerID, placeholder(key))
+		}
+	}
+	sort.Strings(keys)
+	r := strings.NewReplacer(replace...)
+	for _, cm := range objects.ConfigMaps {
+		for k, v := range cm.Data {
+			cm.Data[k] = r.Replace(v)
+		}
+	}
+	return keys, nil
+}
+
+// replaceMePlaceholder turns a secret key into a REPLACE_ME_<KEY> marker. ${VAR}
+// style is avoided on purpose: the scripts ConfigMap is full of shell variables.
+func replaceMePlaceholder(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	return "REPLACE_ME_" + name
+}
+
+// manifestDoc converts an object into a clean manifest map without status,
+// null fields and, when stripNamespace is set, the namespace.
+func manifestDoc(obj runtime.Object, stripNamespace bool) (map[string]interface{}, error) {
+	doc, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	pruneManifest(doc)
+	if stripNamespace {
+		unstructured.RemoveNestedField(doc, "metadata", "namespace")
+	}
+	return doc, nil
+}
+
+func pruneManifest(m map[string]interface{}) {
+	for k, v := range m {
+		switch val := v.(type) {
+		case nil:
+			delete(m, k)
+		case map[string]interface{}:
+			pruneManifest(val)
+			if k == "status" && len(val) == 0 {
+				delete(m, k)
+			}
+		case []interface{}:
+			for _, item := range val {
+				if im, ok := item.(map[string]interface{}); ok {
+					pruneManifest(im)
+				}
+			}
+		}
+	}
+}
+
+func manifestFileName(obj runtime.Object) string {
+	return strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind) + "-" + obj.(metav1.Object).GetName() + ".yaml"
+}
+
+// archive packs the files into a gzipped tar under the root directory.
+func archive(root string, files []manifestFile) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{Name: root + "/" + f.Path, Mode: 0o644, Size: int64(len(f.Data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
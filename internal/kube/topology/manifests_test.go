@@ -0,0 +1,233 @@
+package topology
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"ipfs-visualizer/internal/kube"
+
+	"sigs.k8s.io/yaml"
+)
+
+const testTopologyID = "0f3c2a6e-9b1d-4c7a-8e2f-5a6b7c8d9e0f"
+
+// testDeployConfig is a small private-network topology a -> b -> c with
+// persisted identities and secrets, where c runs in a profile StatefulSet. It
+// also returns every secret value the config holds, raw and base64 encoded as
+// Secret data is rendered.
+func testDeployConfig(t *testing.T) (DeployConfig, []string) {
+	t.Helper()
+	cfg := DeployConfig{
+		TopologyID:      testTopologyID,
+		Name:            "test",
+		Namespace:       "ipfs",
+		Nodes:           testNodes("a", "b", "c"),
+		Edges:           testEdges([2]string{"a", "b"}, [2]string{"b", "c"}),
+		BootstrapIDs:    []string{"a"},
+		PrivateNetwork:  true,
+		NetworkPolicies: true,
+		ClusterSecrets:  []string{strings.Repeat("ab", 32)},
+		SwarmKeys:       []string{strings.Repeat("cd", 32)},
+	}
+	cfg.Nodes[2].Overrides = NodeOverrides{IPFSImageTag: "v0.30.0", Labels: map[string]string{"tier": "edge"}}
+	secrets := append([]string{}, cfg.ClusterSecrets...)
+	secrets = append(secrets, cfg.SwarmKeys...)
+	for i := range cfg.Nodes {
+		var identity NodeIdentity
+		for _, key := range []**kube.BootstrapKeyPair{&identity.ClusterKey, &identity.IPFSKey} {
+			pair, err := kube.GenerateBootstrapPrivateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			*key = pair
+			secrets = append(secrets, pair.PrivateKey, pair.PeerID)
+		}
+		cfg.Nodes[i].Identity = &identity
+	}
+	for _, s := range secrets {
+		secrets = append(secrets, base64.StdEncoding.EncodeToString([]byte(s)))
+	}
+	return cfg, secrets
+}
+
+// untar unpacks a gzipped tar archive into its files by path.
+func untar(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("tar %s: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = body
+	}
+}
+
+var (
+	helmMarkerRe   = regexp.MustCompile(`HELM(VALUE|SECRET|PEER)__`)
+	helmActionRe   = regexp.MustCompile(`\{\{.*?\}\}`)
+	helmRequiredRe = regexp.MustCompile(`index \.Values\.(secrets|peerIds) "([^"]+)"`)
+)
+
+// parseYAMLDocs unmarshals every document of a YAML stream and returns them.
+func parseYAMLDocs(t *testing.T, name string, data []byte) []map[string]interface{} {
+	t.Helper()
+	var docs []map[string]interface{}
+	for _, part := range strings.Split("\n"+string(data), "\n---\n") {
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal([]byte(part), &doc); err != nil {
+			t.Fatalf("%s does not parse as YAML: %v", name, err)
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func TestRenderManifests(t *testing.T) {
+	tests := []struct {
+		format   string
+		fileName string
+		archived bool
+	}{
+		{format: FormatYAML, fileName: "ipfs-0f3c2a6e9b1d.yaml"},
+		{format: FormatHelm, fileName: "ipfs-0f3c2a6e9b1d-helm.tgz", archived: true},
+		{format: FormatKustomize, fileName: "ipfs-0f3c2a6e9b1d-kustomize.tar.gz", archived: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			cfg, secrets := testDeployConfig(t)
+			m, err := RenderManifests(cfg, tt.format)
+			if err != nil {
+				t.Fatalf("RenderManifests() error = %v", err)
+			}
+			if m.FileName != tt.fileName {
+				t.Errorf("FileName = %q, want %q", m.FileName, tt.fileName)
+			}
+			files := map[string][]byte{m.FileName: m.Data}
+			if tt.archived {
+				files = untar(t, m.Data)
+			}
+
+			var paths []string
+			for path := range files {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			kinds := map[string]int{}
+			required := map[string]bool{}
+			var replaceMe bool
+			for _, path := range paths {
+				text := string(files[path])
+				if tt.archived && !strings.HasPrefix(path, "ipfs-0f3c2a6e9b1d/") {
+					t.Errorf("%s is outside the archive root", path)
+				}
+				if loc := helmMarkerRe.FindStringIndex(text); loc != nil {
+					t.Errorf("%s keeps the marker %q", path, text[loc[0]:min(loc[1]+20, len(text))])
+				}
+				for _, secret := range secrets {
+					if strings.Contains(text, secret) {
+						t.Errorf("%s leaks the secret value %q", path, secret)
+					}
+				}
+				replaceMe = replaceMe || strings.Contains(text, "REPLACE_ME_")
+				if tt.format == FormatHelm {
+					for _, match := range helmRequiredRe.FindAllStringSubmatch(text, -1) {
+						required[match[1]+"."+match[2]] = true
+					}
+					text = helmActionRe.ReplaceAllString(text, "x")
+				}
+				for _, doc := range parseYAMLDocs(t, path, []byte(text)) {
+					if kind, _ := doc["kind"].(string); kind != "" {
+						kinds[kind]++
+					}
+				}
+			}
+
+			want := map[string]int{"ConfigMap": 3, "Secret": 2, "Service": 2, "StatefulSet": 2, "NetworkPolicy": 3}
+			if tt.format == FormatKustomize {
+				want["Kustomization"] = 1
+			}
+			if len(kinds) != len(want) {
+				t.Errorf("kinds = %v, want %v", kinds, want)
+			}
+			for kind, n := range want {
+				if kinds[kind] != n {
+					t.Errorf("%d %s objects, want %d", kinds[kind], kind, n)
+				}
+			}
+
+			if tt.format == FormatHelm {
+				if replaceMe {
+					t.Error("helm chart contains REPLACE_ME_ placeholders")
+				}
+				var values struct {
+					Secrets map[string]string `json:"secrets"`
+					PeerIDs map[string]string `json:"peerIds"`
+				}
+				if err := yaml.Unmarshal(files["ipfs-0f3c2a6e9b1d/values.yaml"], &values); err != nil {
+					t.Fatalf("values.yaml: %v", err)
+				}
+				if len(required) == 0 {
+					t.Error("no template requires a secret or peer ID value")
+				}
+				for key := range required {
+					group, name, _ := strings.Cut(key, ".")
+					list := values.Secrets
+					if group == "peerIds" {
+						list = values.PeerIDs
+					}
+					if _, ok := list[name]; !ok {
+						t.Errorf("templates require %s, values.yaml does not declare it", key)
+					}
+				}
+			} else if !replaceMe {
+				t.Error("no REPLACE_ME_ placeholders")
+			}
+			if tt.format == FormatKustomize {
+				var kustomization struct {
+					Resources []string `json:"resources"`
+				}
+				if err := yaml.Unmarshal(files["ipfs-0f3c2a6e9b1d/kustomization.yaml"], &kustomization); err != nil {
+					t.Fatalf("kustomization.yaml: %v", err)
+				}
+				if len(kustomization.Resources) != len(files)-1 {
+					t.Errorf("%d resources, want %d", len(kustomization.Resources), len(files)-1)
+				}
+				for _, r := range kustomization.Resources {
+					if _, ok := files["ipfs-0f3c2a6e9b1d/"+r]; !ok {
+						t.Errorf("resource %s is not in the archive", r)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRenderManifestsUnknownFormat(t *testing.T) {
+	cfg, _ := testDeployConfig(t)
+	if _, err := RenderManifests(cfg, "jsonnet"); err == nil {
+		t.Error("RenderManifests() error = nil, want an unknown format error")
+	}
+}
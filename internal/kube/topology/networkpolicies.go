@@ -0,0 +1,193 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PolicyLabel tells the NetworkPolicies of a topology apart: "edges" for the
+// ones a deploy generates from the canvas, "chaos" for isolated pods.
+const PolicyLabel = "ipfs-visualizer/policy"
+
+const edgePolicy = "edges"
+
+// edgeNeighbours returns the nodes every node shares an edge with, in either
+// direction: a peer dials its targets and is dialled by its sources.
+func edgeNeighbours(cfg DeployConfig) map[string][]string {
+	sets := map[string]map[string]bool{}
+	add := func(a, b string) {
+		if sets[a] == nil {
+			sets[a] = map[string]bool{}
+		}
+		sets[a][b] = true
+	}
+	for _, e := range cfg.Edges {
+		if e.SourceNodeID == e.TargetNodeID {
+			continue
+		}
+		add(e.SourceNodeID, e.TargetNodeID)
+		add(e.TargetNodeID, e.SourceNodeID)
+	}
+	out := make(map[string][]string, len(sets))
+	for nodeID, set := range sets {
+		for n := range set {
+			out[nodeID] = append(out[nodeID], n)
+		}
+		sort.Strings(out[nodeID])
+	}
+	return out
+}
+
+// APISources are who may reach the API, gateway and cluster API ports of a pod
+// under its NetworkPolicy, besides the pods of its own topology.
+type APISources struct {
+	CIDRs      []string // e.g. the addresses the API server proxies from
+	Namespaces []string // e.g. the namespace of the visualizer backend
+}
+
+// IsZero reports whether no source is configured.
+func (s APISources) IsZero() bool {
+	return len(s.CIDRs) == 0 && len(s.Namespaces) == 0
+}
+
+// policyPeers returns the From of the API rule. The visualizer reaches these
+// ports through the API server proxy, whose source address depends on the
+// cluster (a control plane node, a konnectivity agent), so without configured
+// sources the rule has no From and admits everyone. With sources it admits
+// them and the topology's own pods, which wait on each other's cluster API.
+func (s APISources) policyPeers(svcName string) ([]networkingv1.NetworkPolicyPeer, error) {
+	if s.IsZero() {
+		return nil, nil
+	}
+	out := []networkingv1.NetworkPolicyPeer{{
+		PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": svcName}},
+	}}
+	for _, cidr := range s.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("api source %q: %w", cidr, err)
+		}
+		out = append(out, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(s.Namespaces) > 0 {
+		out = append(out, networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      corev1.LabelMetadataName,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   s.Namespaces,
+			}},
+		}})
+	}
+	return out, nil
+}
+
+// buildNetworkPolicies renders one NetworkPolicy per pod: the swarm and
+// cluster-swarm ports accept traffic only from the pods of its edge neighbours,
+// the API, gateway and cluster API ports from cfg.APISources.
+func buildNetworkPolicies(cfg DeployConfig, svcName string, peers []peer, byNode map[string]peer) ([]*networkingv1.NetworkPolicy, error) {
+	apiFrom, err := cfg.APISources.policyPeers(svcName)
+	if err != nil {
+		return nil, err
+	}
+	neighbours := edgeNeighbours(cfg)
+	policies := make([]*networkingv1.NetworkPolicy, 0, len(peers))
+	for _, p := range peers {
+		var pods []string
+		for _, nodeID := range neighbours[p.NodeID] {
+			if np, ok := byNode[nodeID]; ok {
+				pods = append(pods, np.PodName)
+			}
+		}
+		ingress := []networkingv1.NetworkPolicyIngressRule{{From: apiFrom, Ports: apiPolicyPorts()}}
+		// A rule without peers would admit everyone, so a node without
+		// neighbours gets no swarm rule at all.
+		if len(pods) > 0 {
+			ingress = append(ingress, networkingv1.NetworkPolicyIngressRule{
+				From: []networkingv1.NetworkPolicyPeer{{PodSelector: podsSelector(svcName, pods)}},
+				Ports: append(policyPorts(corev1.ProtocolTCP, 4001, 9096),
+					policyPorts(corev1.ProtocolUDP, 4001, 4002)...),
+			})
+		}
+		// A pod isolated by a chaos experiment drops out of its edge policy.
+		selector := podsSelector(svcName, []string{p.PodName})
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      ChaosLabel,
+			Operator: metav1.LabelSelectorOpDoesNotExist,
+		})
+		policies = append(policies, &networkingv1.NetworkPolicy{
+			TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      p.PodName + "-edges",
+				Namespace: cfg.Namespace,
+				Labels:    map[string]string{"app": svcName, PolicyLabel: edgePolicy},
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: *selector,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     ingress,
+			},
+		})
+	}
+	return policies, nil
+}
+
+// podsSelector matches the named pods of a topology.
+func podsSelector(svcName string, pods []string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": svcName},
+		MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      appsv1.StatefulSetPodNameLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   pods,
+		}},
+	}
+}
+
+// apiPolicyPorts are the kubo API, gateway and ipfs-cluster API ports, which
+// the visualizer reaches through the API server proxy.
+func apiPolicyPorts() []networkingv1.NetworkPolicyPort {
+	return policyPorts(corev1.ProtocolTCP, 5001, 8080, 8081, 9094, 9095)
+}
+
+func policyPorts(protocol corev1.Protocol, ports ...int) []networkingv1.NetworkPolicyPort {
+	out := make([]networkingv1.NetworkPolicyPort, 0, len(ports))
+	for _, port := range ports {
+		p := intstr.FromInt(port)
+		out = append(out, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p})
+	}
+	return out
+}
+
+// pruneNetworkPolicies deletes the edge NetworkPolicies of a topology not in
+// keep: those of pods that no longer exist, or all of them when a deploy runs
+// without policies.
+func pruneNetworkPolicies(ctx context.Context, client kubernetes.Interface, svcName, namespace string, keep map[string]bool) ([]string, error) {
+	list, err := client.NetworkingV1().NetworkPolicies(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=" + svcName + "," + PolicyLabel + "=" + edgePolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list networkpolicies: %w", err)
+	}
+	var pruned []string
+	for _, np := range list.Items {
+		if keep[np.Name] {
+			continue
+		}
+		err := client.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, np.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return pruned, fmt.Errorf("delete networkpolicy/%s: %w", np.Name, err)
+		}
+		pruned = append(pruned, np.Name)
+	}
+	sort.Strings(pruned)
+	return pruned, nil
+}
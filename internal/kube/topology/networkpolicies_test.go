@@ -0,0 +1,109 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEdgeNeighbours(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges [][2]string
+		want  map[string][]string
+	}{
+		{
+			name:  "edges count in both directions",
+			edges: [][2]string{{"b", "a"}, {"c", "b"}},
+			want:  map[string][]string{"a": {"b"}, "b": {"a", "c"}, "c": {"b"}},
+		},
+		{
+			name:  "neighbours are sorted",
+			edges: [][2]string{{"a", "d"}, {"a", "b"}, {"c", "a"}},
+			want:  map[string][]string{"a": {"b", "c", "d"}, "b": {"a"}, "c": {"a"}, "d": {"a"}},
+		},
+		{
+			name:  "self-loops are skipped",
+			edges: [][2]string{{"a", "a"}, {"a", "b"}},
+			want:  map[string][]string{"a": {"b"}, "b": {"a"}},
+		},
+		{
+			name:  "duplicate and opposite edges are merged",
+			edges: [][2]string{{"a", "b"}, {"a", "b"}, {"b", "a"}},
+			want:  map[string][]string{"a": {"b"}, "b": {"a"}},
+		},
+		{
+			name: "no edges",
+			want: map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := edgeNeighbours(DeployConfig{Nodes: testNodes("a", "b", "c", "d"), Edges: testEdges(tt.edges...)})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("edgeNeighbours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPISourcesPolicyPeers(t *testing.T) {
+	const svcName = "ipfs-0123456789ab"
+	own := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": svcName}},
+	}
+	tests := []struct {
+		name    string
+		sources APISources
+		want    []networkingv1.NetworkPolicyPeer
+		wantErr bool
+	}{
+		{
+			name: "no sources admit everyone",
+		},
+		{
+			name:    "cidrs and namespaces admit the topology pods too",
+			sources: APISources{CIDRs: []string{"10.0.0.0/8", "192.168.1.10/32"}, Namespaces: []string{"visualizer", "ops"}},
+			want: []networkingv1.NetworkPolicyPeer{
+				own,
+				{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}},
+				{IPBlock: &networkingv1.IPBlock{CIDR: "192.168.1.10/32"}},
+				{NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "kubernetes.io/metadata.name",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{"visualizer", "ops"},
+				}}}},
+			},
+		},
+		{
+			name:    "namespaces only",
+			sources: APISources{Namespaces: []string{"visualizer"}},
+			want: []networkingv1.NetworkPolicyPeer{
+				own,
+				{NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "kubernetes.io/metadata.name",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   []string{"visualizer"},
+				}}}},
+			},
+		},
+		{
+			name:    "an address is not a cidr",
+			sources: APISources{CIDRs: []string{"10.0.0.1"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.sources.policyPeers(svcName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("policyPeers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("policyPeers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ipfs-visualizer/internal/kube"
+)
+
+// peer is a canvas node bound to a StatefulSet pod together with its libp2p identities.
+type peer struct {
+	NodeID     string
+	PodName    string
+	Workload   string // StatefulSet of the pod
+	Ordinal    int
+	Order      int                    // position in orderNodes
+	Network    int                    // index of the ipfs-cluster network (cluster secret) the peer joins
+	ClusterKey *kube.BootstrapKeyPair // ipfs-cluster peer identity
+	IPFSKey    *kube.BootstrapKeyPair // kubo peer identity
+}
+
+type peeringEntry struct {
+	ID    string   `json:"ID"`
+	Addrs []string `json:"Addrs"`
+}
+
+// orderNodes orders the nodes for fresh StatefulSet ordinals: every node is
+// placed after the nodes its outgoing edges point at, so with OrderedReady pod
+// management bootstrap targets are started before the peers that join through
+// them. The order holds within each profile StatefulSet for nodes without a
+// previous ordinal; cycles are broken by node ID. Across StatefulSets and for
+// kept ordinals the entrypoint waits for the targets instead (see startsAfter).
+func orderNodes(cfg DeployConfig) []NodeInfo {
+	byID := make(map[string]NodeInfo, len(cfg.Nodes))
+	for _, n := range cfg.Nodes {
+		byID[n.NodeID] = n
+	}
+	targets := outgoingTargets(cfg)
+
+	remaining := make([]string, 0, len(cfg.Nodes))
+	for _, n := range cfg.Nodes {
+		remaining = append(remaining, n.NodeID)
+	}
+	sort.Strings(remaining)
+
+	placed := make(map[string]bool, len(cfg.Nodes))
+	out := make([]NodeInfo, 0, len(cfg.Nodes))
+	place := func(id string) {
+		placed[id] = true
+		out = append(out, byID[id])
+	}
+	for _, id := range cfg.BootstrapIDs {
+		if _, ok := byID[id]; ok && !placed[id] {
+			place(id)
+		}
+	}
+
+	for len(out) < len(byID) {
+		next := ""
+		for _, id := range remaining {
+			if placed[id] {
+				continue
+			}
+			ready := true
+			for _, t := range targets[id] {
+				if !placed[t] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = id
+				break
+			}
+		}
+		if next == "" {
+			for _, id := range remaining {
+				if !placed[id] {
+					next = id
+					break
+				}
+			}
+		}
+		place(next)
+	}
+	return out
+}
+
+// networkIndex maps every node to the index of the network it belongs to.
+func networkIndex(cfg DeployConfig) map[string]int {
+	out := make(map[string]int, len(cfg.Nodes))
+	for i, ids := range cfg.Networks {
+		for _, id := range ids {
+			out[id] = i
+		}
+	}
+	return out
+}
+
+func clusterSecretKey(network int) string {
+	return fmt.Sprintf("cluster-secret-%d", network)
+}
+
+func swarmKeyKey(network int) string {
+	return fmt.Sprintf("swarm-key-%d", network)
+}
+
+// outgoingTargets returns, for every node, the distinct existing nodes its edges point at.
+func outgoingTargets(cfg DeployConfig) map[string][]string {
+	known := make(map[string]bool, len(cfg.Nodes))
+	for _, n := range cfg.Nodes {
+		known[n.NodeID] = true
+	}
+	seen := make(map[[2]string]bool, len(cfg.Edges))
+	out := make(map[string][]string, len(cfg.Nodes))
+	for _, e := range cfg.Edges {
+		key := [2]string{e.SourceNodeID, e.TargetNodeID}
+		if e.SourceNodeID == e.TargetNodeID || seen[key] || !known[e.SourceNodeID] || !known[e.TargetNodeID] {
+			continue
+		}
+		seen[key] = true
+		out[e.SourceNodeID] = append(out[e.SourceNodeID], e.TargetNodeID)
+	}
+	return out
+}
+
+// newPeers binds the ordered nodes to pods. A node keeps the ordinal of its
+// previous assignment while it stays in the same StatefulSet and the ordinal
+// is still within its replicas, so a redeploy does not move nodes between pods
+// and their volumes. The other nodes fill the free ordinals in order.
+func newPeers(nodes []NodeInfo, profiles map[string]string, networks map[string]int, previous []PodAssignment) ([]peer, error) {
+	ordinals := assignOrdinals(nodes, profiles, previous)
+	peers := make([]peer, 0, len(nodes))
+	for order, n := range nodes {
+		workload := profiles[n.NodeID]
+		i := ordinals[n.NodeID]
+		identity := n.Identity
+		if identity == nil {
+			clusterKey, err := kube.GenerateBootstrapPrivateKey()
+			if err != nil {
+				return nil, fmt.Errorf("generate cluster key for node %s: %w", n.NodeID, err)
+			}
+			ipfsKey, err := kube.GenerateBootstrapPrivateKey()
+			if err != nil {
+				return nil, fmt.Errorf("generate ipfs key for node %s: %w", n.NodeID, err)
+			}
+			identity = &NodeIdentity{ClusterKey: clusterKey, IPFSKey: ipfsKey}
+		}
+		peers = append(peers, peer{
+			NodeID:     n.NodeID,
+			PodName:    fmt.Sprintf("%s-%d", workload, i),
+			Workload:   workload,
+			Ordinal:    i,
+			Order:      order,
+			Network:    networks[n.NodeID],
+			ClusterKey: identity.ClusterKey,
+			IPFSKey:    identity.IPFSKey,
+		})
+	}
+	return peers, nil
+}
+
+// assignOrdinals returns the ordinal of every node within its StatefulSet,
+// seeded from the previous assignment. Ordinals stay dense, 0 to replicas-1:
+// a kept ordinal must be below the new replica count, and nodes without one
+// take the lowest free ordinals in the given order.
+func assignOrdinals(nodes []NodeInfo, profiles map[string]string, previous []PodAssignment) map[string]int {
+	replicas := map[string]int{}
+	for _, n := range nodes {
+		replicas[profiles[n.NodeID]]++
+	}
+	prev := make(map[string]PodAssignment, len(previous))
+	for _, a := range previous {
+		prev[a.NodeID] = a
+	}
+
+	out := make(map[string]int, len(nodes))
+	taken := map[string]map[int]bool{}
+	var fresh []NodeInfo
+	for _, n := range nodes {
+		workload := profiles[n.NodeID]
+		if taken[workload] == nil {
+			taken[workload] = map[int]bool{}
+		}
+		a, ok := prev[n.NodeID]
+		if ok && a.Workload == workload && a.Ordinal >= 0 && a.Ordinal < replicas[workload] && !taken[workload][a.Ordinal] {
+			taken[workload][a.Ordinal] = true
+			out[n.NodeID] = a.Ordinal
+			continue
+		}
+		fresh = append(fresh, n)
+	}
+	next := map[string]int{}
+	for _, n := range fresh {
+		workload := profiles[n.NodeID]
+		i := next[workload]
+		for taken[workload][i] {
+			i++
+		}
+		taken[workload][i] = true
+		next[workload] = i + 1
+		out[n.NodeID] = i
+	}
+	return out
+}
+
+// peerEnv renders the shell env file sourced by the pod scripts: the pod's own
+// peer IDs and network secret plus the cluster bootstrap addresses and kubo
+// peering entries of the nodes its outgoing edges point at, the targets the
+// entrypoint waits for, its kubo init profiles and, in a private network, the
+// swarm key of its network.
+func peerEnv(svcName string, p peer, byNode map[string]peer, targets []string, kubo KuboConfig, privateNetwork bool) (string, error) {
+	bootstrap := make([]string, 0, len(targets))
+	peering := make([]peeringEntry, 0, len(targets))
+	var wait []string
+	for _, t := range targets {
+		tp, ok := byNode[t]
+		if !ok {
+			continue
+		}
+		host := tp.PodName + "." + svcName
+		bootstrap = append(bootstrap, "/dns4/"+host+"/tcp/9096/p2p/"+tp.ClusterKey.PeerID)
+		peering = append(peering, peeringEntry{ID: tp.IPFSKey.PeerID, Addrs: []string{"/dns4/" + host + "/tcp/4001"}})
+		if startsAfter(tp, p) {
+			wait = append(wait, host)
+		}
+	}
+	peeringJSON, err := json.Marshal(peering)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("NODE_ID=" + shellQuote(p.NodeID) + "\n")
+	b.WriteString("CLUSTER_PEER_ID=" + shellQuote(p.ClusterKey.PeerID) + "\n")
+	b.WriteString("IPFS_PEER_ID=" + shellQuote(p.IPFSKey.PeerID) + "\n")
+	b.WriteString("CLUSTER_SECRET_KEY=" + shellQuote(clusterSecretKey(p.Network)) + "\n")
+	b.WriteString("CLUSTER_BOOTSTRAP=" + shellQuote(strings.Join(bootstrap, ",")) + "\n")
+	b.WriteString("CLUSTER_WAIT_FOR=" + shellQuote(strings.Join(wait, " ")) + "\n")
+	b.WriteString("IPFS_PEERING=" + shellQuote(string(peeringJSON)) + "\n")
+	b.WriteString("IPFS_PROFILES=" + shellQuote(strings.Join(kubo.Profiles, ",")) + "\n")
+	swarmKey := ""
+	if privateNetwork {
+		swarmKey = swarmKeyKey(p.Network)
+	}
+	b.WriteString("SWARM_KEY_KEY=" + shellQuote(swarmKey) + "\n")
+	return b.String(), nil
+}
+
+// startsAfter reports whether OrderedReady pod management may start target
+// after p although orderNodes placed it before: it runs in another StatefulSet,
+// or kept a higher ordinal than p from an earlier deploy. Waiting only for the
+// targets placed before p cannot deadlock on a cycle.
+func startsAfter(target, p peer) bool {
+	return target.Order < p.Order && (target.Workload != p.Workload || target.Ordinal > p.Ordinal)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
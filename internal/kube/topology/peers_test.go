@@ -0,0 +1,193 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testNodes(ids ...string) []NodeInfo {
+	nodes := make([]NodeInfo, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, NodeInfo{NodeID: id})
+	}
+	return nodes
+}
+
+func testEdges(pairs ...[2]string) []EdgeInfo {
+	edges := make([]EdgeInfo, 0, len(pairs))
+	for _, p := range pairs {
+		edges = append(edges, EdgeInfo{SourceNodeID: p[0], TargetNodeID: p[1]})
+	}
+	return edges
+}
+
+func TestOrderNodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []string
+		edges     [][2]string
+		bootstrap []string
+		want      []string
+	}{
+		{
+			name:      "chain follows edge targets",
+			nodes:     []string{"c", "b", "a"},
+			edges:     [][2]string{{"c", "b"}, {"b", "a"}},
+			bootstrap: []string{"a"},
+			want:      []string{"a", "b", "c"},
+		},
+		{
+			name:      "bootstrap nodes come first",
+			nodes:     []string{"a", "b"},
+			edges:     [][2]string{{"b", "a"}},
+			bootstrap: []string{"b"},
+			want:      []string{"b", "a"},
+		},
+		{
+			name:      "nodes without edges are sorted by ID",
+			nodes:     []string{"y", "z", "x"},
+			bootstrap: []string{"z"},
+			want:      []string{"z", "x", "y"},
+		},
+		{
+			name:  "cycle is broken by node ID",
+			nodes: []string{"b", "a"},
+			edges: [][2]string{{"a", "b"}, {"b", "a"}},
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "node waits for every target",
+			nodes: []string{"a", "b", "c"},
+			edges: [][2]string{{"a", "b"}, {"a", "c"}, {"b", "c"}},
+			want:  []string{"c", "b", "a"},
+		},
+		{
+			name:      "unknown and repeated bootstrap IDs are ignored",
+			nodes:     []string{"b", "a"},
+			bootstrap: []string{"missing", "b", "b"},
+			want:      []string{"b", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DeployConfig{Nodes: testNodes(tt.nodes...), Edges: testEdges(tt.edges...), BootstrapIDs: tt.bootstrap}
+			var got []string
+			for _, n := range orderNodes(cfg) {
+				got = append(got, n.NodeID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderNodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutgoingTargets(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []string
+		edges [][2]string
+		want  map[string][]string
+	}{
+		{
+			name:  "targets in edge order",
+			nodes: []string{"a", "b", "c"},
+			edges: [][2]string{{"a", "c"}, {"a", "b"}, {"b", "c"}},
+			want:  map[string][]string{"a": {"c", "b"}, "b": {"c"}},
+		},
+		{
+			name:  "self-loops and duplicates are skipped",
+			nodes: []string{"a", "b"},
+			edges: [][2]string{{"a", "a"}, {"a", "b"}, {"a", "b"}},
+			want:  map[string][]string{"a": {"b"}},
+		},
+		{
+			name:  "edges to or from unknown nodes are skipped",
+			nodes: []string{"a", "b"},
+			edges: [][2]string{{"a", "x"}, {"x", "b"}, {"b", "a"}},
+			want:  map[string][]string{"b": {"a"}},
+		},
+		{
+			name:  "no edges",
+			nodes: []string{"a"},
+			want:  map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outgoingTargets(DeployConfig{Nodes: testNodes(tt.nodes...), Edges: testEdges(tt.edges...)})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outgoingTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignOrdinals(t *testing.T) {
+	const def, other = "svc", "svc-n1234567"
+	tests := []struct {
+		name     string
+		nodes    []string
+		profiles map[string]string
+		previous []PodAssignment
+		want     map[string]int
+	}{
+		{
+			name:  "first deploy numbers nodes in order",
+			nodes: []string{"a", "b", "c"},
+			want:  map[string]int{"a": 0, "b": 1, "c": 2},
+		},
+		{
+			name:     "nodes keep their ordinals when the order changes",
+			nodes:    []string{"a", "b", "c"},
+			previous: []PodAssignment{{NodeID: "a", Workload: def, Ordinal: 2}, {NodeID: "b", Workload: def, Ordinal: 0}, {NodeID: "c", Workload: def, Ordinal: 1}},
+			want:     map[string]int{"a": 2, "b": 0, "c": 1},
+		},
+		{
+			name:     "new node takes the ordinal a removed node freed",
+			nodes:    []string{"a", "c", "d"},
+			previous: []PodAssignment{{NodeID: "a", Workload: def, Ordinal: 0}, {NodeID: "b", Workload: def, Ordinal: 1}, {NodeID: "c", Workload: def, Ordinal: 2}},
+			want:     map[string]int{"a": 0, "c": 2, "d": 1},
+		},
+		{
+			name:     "new node is appended",
+			nodes:    []string{"a", "b", "c"},
+			previous: []PodAssignment{{NodeID: "a", Workload: def, Ordinal: 0}, {NodeID: "b", Workload: def, Ordinal: 1}},
+			want:     map[string]int{"a": 0, "b": 1, "c": 2},
+		},
+		{
+			name:     "ordinal beyond the replicas moves down",
+			nodes:    []string{"a", "c"},
+			previous: []PodAssignment{{NodeID: "a", Workload: def, Ordinal: 0}, {NodeID: "c", Workload: def, Ordinal: 2}},
+			want:     map[string]int{"a": 0, "c": 1},
+		},
+		{
+			name:     "ordinal of another workload is not kept",
+			nodes:    []string{"a", "b"},
+			profiles: map[string]string{"a": def, "b": other},
+			previous: []PodAssignment{{NodeID: "a", Workload: def, Ordinal: 0}, {NodeID: "b", Workload: def, Ordinal: 1}},
+			want:     map[string]int{"a": 0, "b": 0},
+		},
+		{
+			name:     "duplicate ordinal goes to the first node",
+			nodes:    []string{"a", "b"},
+			previous: []PodAssignment{{NodeID: "a", Workload: def, Ordinal: 1}, {NodeID: "b", Workload: def, Ordinal: 1}},
+			want:     map[string]int{"a": 1, "b": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profiles := map[string]string{}
+			for _, id := range tt.nodes {
+				profiles[id] = def
+			}
+			for id, w := range tt.profiles {
+				profiles[id] = w
+			}
+			got := assignOrdinals(testNodes(tt.nodes...), profiles, tt.previous)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("assignOrdinals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package topology
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+const redactedValue = "<redacted>"
+
+// PlannedObject is a rendered object of a deploy plan together with the error
+// the API server returned for it on a server-side dry run.
+type PlannedObject struct {
+	Kind   string         `json:"kind"`
+	Name   string         `json:"name"`
+	Object runtime.Object `json:"object"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// Plan renders the objects Deploy would create without persisting them. Secret
+// values are redacted. With serverDryRun every object is also server-side applied
+// with DryRun: All so validation and admission errors are reported per object.
+func Plan(ctx context.Context, client kubernetes.Interface, cfg DeployConfig, serverDryRun bool) ([]PlannedObject, []PodAssignment, error) {
+	objects, assignments, err := BuildObjects(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	planned := make([]PlannedObject, 0, len(objects.List()))
+	for _, obj := range objects.List() {
+		p := PlannedObject{
+			Kind:   obj.GetObjectKind().GroupVersionKind().Kind,
+			Name:   obj.(metav1.Object).GetName(),
+			Object: obj,
+		}
+		if serverDryRun {
+			if _, err := applyObject(ctx, client, cfg.Namespace, obj, true); err != nil {
+				p.Error = err.Error()
+			}
+		}
+		if s, ok := obj.(*corev1.Secret); ok {
+			p.Object = redactSecret(s)
+		}
+		planned = append(planned, p)
+	}
+	return planned, assignments, nil
+}
+
+func redactSecret(s *corev1.Secret) *corev1.Secret {
+	out := s.DeepCopy()
+	out.StringData = make(map[string]string, len(s.Data)+len(s.StringData))
+	for k := range s.Data {
+		out.StringData[k] = redactedValue
+	}
+	for k := range s.StringData {
+		out.StringData[k] = redactedValue
+	}
+	out.Data = nil
+	return out
+}
@@ -0,0 +1,60 @@
+package topology
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPlanRedactsSecrets(t *testing.T) {
+	cfg, _ := testDeployConfig(t)
+	// Peer IDs are public and show up in the env files, private keys and
+	// network secrets must not show up anywhere.
+	secrets := append(append([]string{}, cfg.ClusterSecrets...), cfg.SwarmKeys...)
+	for _, n := range cfg.Nodes {
+		secrets = append(secrets, n.Identity.ClusterKey.PrivateKey, n.Identity.IPFSKey.PrivateKey)
+	}
+
+	planned, _, err := Plan(context.Background(), nil, cfg, false)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	data, err := json.Marshal(planned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range secrets {
+		for _, value := range []string{secret, base64.StdEncoding.EncodeToString([]byte(secret))} {
+			if strings.Contains(string(data), value) {
+				t.Errorf("plan leaks the secret value %q", value)
+			}
+		}
+	}
+
+	var found int
+	for _, p := range planned {
+		s, ok := p.Object.(*corev1.Secret)
+		if !ok {
+			continue
+		}
+		found++
+		if len(s.Data) != 0 {
+			t.Errorf("%s keeps %d data values", p.Name, len(s.Data))
+		}
+		if len(s.StringData) == 0 {
+			t.Errorf("%s lists no keys", p.Name)
+		}
+		for k, v := range s.StringData {
+			if v != redactedValue {
+				t.Errorf("%s %s = %q, want %q", p.Name, k, v, redactedValue)
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("%d planned Secrets, want 2", found)
+	}
+}
@@ -0,0 +1,180 @@
+package topology
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// WorkloadLabel on the pods of a profile StatefulSet names the StatefulSet. Pods
+// of the default StatefulSet <svc> only carry app=<svc>.
+const WorkloadLabel = "ipfs-visualizer/workload"
+
+// profileHashLen is the number of hex digits of the node ID hash in the name of
+// a profile StatefulSet.
+const profileHashLen = 7
+
+var (
+	imageTagRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	envNameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// NodeOverrides customise the pod of one node on top of the topology workload.
+// A node with overrides runs in its own StatefulSet (a profile); nodes without
+// overrides stay in the default StatefulSet <svc>.
+type NodeOverrides struct {
+	IPFSImageTag       string            `json:"ipfsImageTag,omitempty"`    // replaces the tag of the kubo image
+	ClusterImageTag    string            `json:"clusterImageTag,omitempty"` // replaces the tag of the ipfs-cluster image
+	IPFSStorageSize    string            `json:"ipfsStorageSize,omitempty"`
+	ClusterStorageSize string            `json:"clusterStorageSize,omitempty"`
+	IPFSResources      Resources         `json:"ipfsResources,omitzero"`
+	ClusterResources   Resources         `json:"clusterResources,omitzero"`
+	Env                map[string]string `json:"env,omitempty"` // extra env of the kubo and ipfs-cluster containers
+	Labels             map[string]string `json:"labels,omitempty"`
+	Annotations        map[string]string `json:"annotations,omitempty"`
+}
+
+// IsZero reports whether the overrides change nothing.
+func (o NodeOverrides) IsZero() bool {
+	return o.IPFSImageTag == "" && o.ClusterImageTag == "" &&
+		o.IPFSStorageSize == "" && o.ClusterStorageSize == "" &&
+		o.IPFSResources == (Resources{}) && o.ClusterResources == (Resources{}) &&
+		len(o.Env) == 0 && len(o.Labels) == 0 && len(o.Annotations) == 0
+}
+
+// Validate checks the overrides on their own. Requests against limits inherited
+// from the topology workload are checked on deploy.
+func (o NodeOverrides) Validate() error {
+	for _, tag := range []string{o.IPFSImageTag, o.ClusterImageTag} {
+		if tag != "" && !imageTagRe.MatchString(tag) {
+			return fmt.Errorf("invalid image tag %q", tag)
+		}
+	}
+	w := Workload{
+		IPFSStorageSize:    o.IPFSStorageSize,
+		ClusterStorageSize: o.ClusterStorageSize,
+		IPFSResources:      o.IPFSResources,
+		ClusterResources:   o.ClusterResources,
+	}
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	for name := range o.Env {
+		if !envNameRe.MatchString(name) {
+			return fmt.Errorf("invalid env name %q", name)
+		}
+	}
+	for k, v := range o.Labels {
+		if k == "app" || k == WorkloadLabel {
+			return fmt.Errorf("label %q is reserved", k)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("invalid value of label %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	for k := range o.Annotations {
+		if k == ConfigHashAnnotation {
+			return fmt.Errorf("annotation %q is reserved", k)
+		}
+		if errs := validation.IsQualifiedName(strings.ToLower(k)); len(errs) > 0 {
+			return fmt.Errorf("invalid annotation %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// apply returns the resolved workload w with the overrides applied.
+func (o NodeOverrides) apply(w Workload) Workload {
+	w.IPFSImage = withTag(w.IPFSImage, o.IPFSImageTag)
+	w.ClusterImage = withTag(w.ClusterImage, o.ClusterImageTag)
+	w.IPFSStorageSize = cmp.Or(o.IPFSStorageSize, w.IPFSStorageSize)
+	w.ClusterStorageSize = cmp.Or(o.ClusterStorageSize, w.ClusterStorageSize)
+	w.IPFSResources = o.IPFSResources.or(w.IPFSResources)
+	w.ClusterResources = o.ClusterResources.or(w.ClusterResources)
+	return w
+}
+
+// envVars returns the extra env sorted by name.
+func (o NodeOverrides) envVars() []corev1.EnvVar {
+	out := make([]corev1.EnvVar, 0, len(o.Env))
+	for name, value := range o.Env {
+		out = append(out, corev1.EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// withTag replaces the tag or digest of an image reference; an empty tag keeps it.
+func withTag(image, tag string) string {
+	if tag == "" {
+		return image
+	}
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	} else if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + ":" + tag
+}
+
+// profile is one StatefulSet of a topology: the default one with the nodes
+// without overrides, or the one of a node with overrides.
+type profile struct {
+	Name      string // StatefulSet name
+	Overrides NodeOverrides
+	Replicas  int32
+	Nodes     []string
+}
+
+// profileName returns the StatefulSet of a node: <svc> without overrides,
+// <svc>-n<hash of the node ID> otherwise. The name does not depend on the
+// overrides, so editing them rolls the pod of the node instead of moving it to
+// another StatefulSet with new volumes.
+func profileName(svcName, nodeID string, o NodeOverrides) string {
+	if o.IsZero() {
+		return svcName
+	}
+	sum := sha256.Sum256([]byte(nodeID))
+	return svcName + "-n" + hex.EncodeToString(sum[:])[:profileHashLen]
+}
+
+// nodeProfiles groups the ordered nodes into profiles, in order of their first
+// node, and returns the profile name of every node.
+func nodeProfiles(svcName string, nodes []NodeInfo) ([]*profile, map[string]string) {
+	var profiles []*profile
+	byName := map[string]*profile{}
+	byNode := make(map[string]string, len(nodes))
+	for _, n := range nodes {
+		name := profileName(svcName, n.NodeID, n.Overrides)
+		p, ok := byName[name]
+		if !ok {
+			p = &profile{Name: name, Overrides: n.Overrides}
+			byName[name] = p
+			profiles = append(profiles, p)
+		}
+		p.Replicas++
+		p.Nodes = append(p.Nodes, n.NodeID)
+		byNode[n.NodeID] = name
+	}
+	return profiles, byNode
+}
+
+// isTopologyWorkload matches the StatefulSets of a topology: <svc> and the
+// <svc>-n<hash> of nodes with overrides.
+func isTopologyWorkload(svcName, name string) bool {
+	if name == svcName {
+		return true
+	}
+	hash, ok := strings.CutPrefix(name, svcName+"-n")
+	return ok && len(hash) == profileHashLen && strings.Trim(hash, "0123456789abcdef") == ""
+}
@@ -0,0 +1,133 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Container ports reached through the API server proxy.
+const (
+	PortIPFSAPI    = 5001
+	PortGateway    = 8080
+	PortClusterAPI = 9094
+)
+
+// maxErrorBody caps how much of a failed proxied response ends up in the error.
+const maxErrorBody = 1024
+
+// ProxyRequest is an HTTP request to a pod port sent through the API server.
+type ProxyRequest struct {
+	Method string // GET if empty
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   io.Reader
+}
+
+// ProxyPod sends req to a port of a pod through the API server pod proxy
+// (/api/v1/namespaces/<ns>/pods/<pod>:<port>/proxy/<path>). The response is
+// returned as is, whatever its status; the caller closes the body.
+func ProxyPod(ctx context.Context, client kubernetes.Interface, namespace, podName string, port int, req ProxyRequest) (*http.Response, error) {
+	rc, ok := client.CoreV1().RESTClient().(*rest.RESTClient)
+	if !ok || rc == nil || rc.Client == nil {
+		return nil, errors.New("kubernetes client does not support the pod proxy")
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	r := rc.Verb(method).
+		Namespace(namespace).
+		Resource("pods").
+		Name(fmt.Sprintf("%s:%d", podName, port)).
+		SubResource("proxy").
+		Suffix(req.Path)
+	for k, vs := range req.Query {
+		for _, v := range vs {
+			r.Param(k, v)
+		}
+	}
+
+	u := r.URL()
+	// The request builder cleans the path; a trailing slash matters to the gateway.
+	if strings.HasSuffix(req.Path, "/") && !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u.String(), req.Body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range req.Header {
+		httpReq.Header[k] = vs
+	}
+	// Redirects are returned to the caller: their Location points into the API
+	// server proxy and has to be mapped back to our own routes.
+	hc := *rc.Client
+	hc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return hc.Do(httpReq)
+}
+
+// ProxyPath returns the path of a proxied Location relative to the pod, or
+// false if it does not point into the pod proxy.
+func ProxyPath(location string) (string, bool) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", false
+	}
+	if _, rest, ok := strings.Cut(u.Path, "/proxy/"); ok {
+		u.Path = "/" + rest
+	} else if !strings.HasPrefix(u.Path, "/ipfs/") && !strings.HasPrefix(u.Path, "/ipns/") {
+		return "", false
+	}
+	u.Scheme, u.Host = "", ""
+	return u.String(), true
+}
+
+// proxyJSON sends req to a pod port and decodes a 2xx JSON response into out.
+func proxyJSON(ctx context.Context, client kubernetes.Interface, namespace, podName string, port int, req ProxyRequest, out any) error {
+	resp, err := ProxyPod(ctx, client, namespace, podName, port, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := proxyError(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// proxyError turns a non-2xx proxied response into an error carrying the start
+// of its body.
+func proxyError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &ProxyError{StatusCode: resp.StatusCode, Message: msg}
+}
+
+// ProxyError is a non-2xx answer of a pod API, or of the API server proxying it.
+type ProxyError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("pod API returned %d: %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,57 @@
+package topology
+
+import "testing"
+
+func TestProxyPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		want     string
+		ok       bool
+	}{
+		{
+			name:     "absolute pod proxy URL",
+			location: "https://10.0.0.1:6443/api/v1/namespaces/ns/pods/ipfs-abc-0:8080/proxy/ipfs/bafy/dir/?filename=a",
+			want:     "/ipfs/bafy/dir/?filename=a",
+			ok:       true,
+		},
+		{
+			name:     "pod proxy root",
+			location: "/api/v1/namespaces/ns/pods/ipfs-abc-0:8080/proxy/",
+			want:     "/",
+			ok:       true,
+		},
+		{
+			name:     "gateway path",
+			location: "/ipfs/bafy/index.html",
+			want:     "/ipfs/bafy/index.html",
+			ok:       true,
+		},
+		{
+			name:     "ipns path on another host",
+			location: "http://gateway.local/ipns/example.com/",
+			want:     "/ipns/example.com/",
+			ok:       true,
+		},
+		{
+			name:     "unrelated URL",
+			location: "https://example.com/login",
+		},
+		{
+			name:     "relative path",
+			location: "bafy/index.html",
+		},
+		{
+			name:     "invalid URL",
+			location: "http://[::1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ProxyPath(tt.location)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ProxyPath(%q) = %q, %v, want %q, %v", tt.location, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package topology
+
+import (
+	"context"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RolloutStatus is the progress of the topology StatefulSets towards their desired state.
+type RolloutStatus struct {
+	Replicas int32
+	Ready    int32
+	Updated  int32
+	Current  bool // the controller has observed the latest specs and finished the rolling updates
+	Pods     []PodProgress
+}
+
+// PodProgress is the readiness of one pod and, if it is stuck, the reason.
+type PodProgress struct {
+	PodName string
+	Ready   bool
+	Reason  string
+}
+
+// Done reports whether every replica runs the latest spec and is ready.
+func (s *RolloutStatus) Done() bool {
+	return s.Current && s.Updated == s.Replicas && s.Ready == s.Replicas
+}
+
+// GetRolloutStatus sums up the StatefulSets of every profile and reads their pods.
+// Pods of pruned StatefulSets that are still terminating are left out.
+func GetRolloutStatus(ctx context.Context, client kubernetes.Interface, topologyID, namespace string) (*RolloutStatus, error) {
+	svcName := ServiceName(topologyID)
+	list, err := listStatefulSets(ctx, client, topologyID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, apierrors.NewNotFound(appsv1.Resource("statefulsets"), svcName)
+	}
+	status := &RolloutStatus{Current: true}
+	for _, sts := range list {
+		status.Ready += sts.Status.ReadyReplicas
+		status.Updated += sts.Status.UpdatedReplicas
+		status.Current = status.Current && sts.Status.ObservedGeneration >= sts.Generation && sts.Status.CurrentRevision == sts.Status.UpdateRevision
+		if sts.Spec.Replicas != nil {
+			status.Replicas += *sts.Spec.Replicas
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=" + svcName})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pods.Items {
+		if p.DeletionTimestamp != nil && !ownedBy(&p, list) {
+			continue
+		}
+		status.Pods = append(status.Pods, PodProgress{PodName: p.Name, Ready: podReady(&p), Reason: podProblem(&p)})
+	}
+	sort.Slice(status.Pods, func(i, j int) bool { return status.Pods[i].PodName < status.Pods[j].PodName })
+	return status, nil
+}
+
+// ownedBy reports whether a pod belongs to one of the StatefulSets.
+func ownedBy(p *corev1.Pod, list []appsv1.StatefulSet) bool {
+	for _, ref := range p.OwnerReferences {
+		for _, sts := range list {
+			if ref.UID == sts.UID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func podReady(p *corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// podProblem explains why a pod does not make progress: it cannot be scheduled or
+// a container is waiting for something other than a normal start.
+func podProblem(p *corev1.Pod) string {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason != "" {
+			return c.Reason + ": " + c.Message
+		}
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if w := cs.State.Waiting; w != nil {
+			switch w.Reason {
+			case "", "ContainerCreating", "PodInitializing":
+				continue
+			}
+			if w.Message != "" {
+				return cs.Name + ": " + w.Reason + ": " + w.Message
+			}
+			return cs.Name + ": " + w.Reason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,210 @@
+package topology
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	PodAdded   = "added"
+	PodUpdated = "updated"
+	PodDeleted = "deleted"
+)
+
+// PodState is the part of a pod's status the canvas renders.
+type PodState struct {
+	PodName  string
+	Phase    string
+	Ready    bool
+	Restarts int32
+	Reason   string
+}
+
+type PodEvent struct {
+	Type string // added | updated | deleted
+	Pod  PodState
+}
+
+// PodWatchers shares one pod informer per topology (label app=<svcName>) between
+// all status stream subscribers. The informer is stopped with its last subscriber.
+type PodWatchers struct {
+	client   kubernetes.Interface
+	mu       sync.Mutex
+	watchers map[string]*podWatcher
+}
+
+type podWatcher struct {
+	factory  informers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+	stop     chan struct{}
+	refs     int
+}
+
+func NewPodWatchers(client kubernetes.Interface) *PodWatchers {
+	return &PodWatchers{client: client, watchers: map[string]*podWatcher{}}
+}
+
+// Subscribe streams pod changes of a topology until ctx is done. The current pods
+// are delivered first as added events. Updates that do not change the PodState are
+// skipped, and a subscriber that falls behind gets only the latest state of each
+// pod instead of losing events.
+func (pw *PodWatchers) Subscribe(ctx context.Context, topologyID, namespace string) (<-chan PodEvent, error) {
+	svcName := ServiceName(topologyID)
+	key := namespace + "/" + svcName
+	w := pw.acquire(key, namespace, svcName)
+
+	sub := newPodSubscriber()
+	reg, err := w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if p, ok := obj.(*corev1.Pod); ok {
+				sub.send(PodEvent{Type: PodAdded, Pod: podState(p)})
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok1 := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			if !ok1 || !ok2 {
+				return
+			}
+			if state := podState(newPod); state != podState(oldPod) {
+				sub.send(PodEvent{Type: PodUpdated, Pod: state})
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if p, ok := obj.(*corev1.Pod); ok {
+				sub.send(PodEvent{Type: PodDeleted, Pod: podState(p)})
+			}
+		},
+	})
+	if err != nil {
+		pw.release(key)
+		return nil, err
+	}
+
+	events := make(chan PodEvent)
+	go func() {
+		sub.run(ctx, events)
+		_ = w.informer.RemoveEventHandler(reg)
+		pw.release(key)
+	}()
+	return events, nil
+}
+
+func (pw *PodWatchers) acquire(key, namespace, svcName string) *podWatcher {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if w, ok := pw.watchers[key]; ok {
+		w.refs++
+		return w
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(pw.client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) { o.LabelSelector = "app=" + svcName }),
+	)
+	w := &podWatcher{
+		factory:  factory,
+		informer: factory.Core().V1().Pods().Informer(),
+		stop:     make(chan struct{}),
+		refs:     1,
+	}
+	factory.Start(w.stop)
+	pw.watchers[key] = w
+	return w
+}
+
+func (pw *PodWatchers) release(key string) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	w, ok := pw.watchers[key]
+	if !ok {
+		return
+	}
+	w.refs--
+	if w.refs > 0 {
+		return
+	}
+	delete(pw.watchers, key)
+	close(w.stop)
+	go w.factory.Shutdown()
+}
+
+// podSubscriber is one stream. Events wait in a latest-wins map by pod until the
+// stream takes them, so a slow reader never blocks the informer and never
+// misses the final state of a pod.
+type podSubscriber struct {
+	mu      sync.Mutex
+	pending map[string]PodEvent
+	order   []string // pods in pending, in order of their first event
+	notify  chan struct{}
+}
+
+func newPodSubscriber() *podSubscriber {
+	return &podSubscriber{pending: map[string]PodEvent{}, notify: make(chan struct{}, 1)}
+}
+
+// send queues e over any pending event of the same pod. An update of a pod
+// whose add was not delivered yet is still reported as added.
+func (s *podSubscriber) send(e PodEvent) {
+	s.mu.Lock()
+	name := e.Pod.PodName
+	if prev, ok := s.pending[name]; !ok {
+		s.order = append(s.order, name)
+	} else if prev.Type == PodAdded && e.Type == PodUpdated {
+		e.Type = PodAdded
+	}
+	s.pending[name] = e
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending events and clears them.
+func (s *podSubscriber) take() []PodEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]PodEvent, 0, len(s.order))
+	for _, name := range s.order {
+		out = append(out, s.pending[name])
+	}
+	clear(s.pending)
+	s.order = s.order[:0]
+	return out
+}
+
+// run delivers the pending events to out until ctx is done, then closes out.
+func (s *podSubscriber) run(ctx context.Context, out chan<- PodEvent) {
+	defer close(out)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+		}
+		for _, e := range s.take() {
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func podState(p *corev1.Pod) PodState {
+	s := PodState{PodName: p.Name, Phase: string(p.Status.Phase), Ready: podReady(p), Reason: podProblem(p)}
+	for _, cs := range p.Status.ContainerStatuses {
+		s.Restarts += cs.RestartCount
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package topology
+
+import (
+	"cmp"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	DefaultIPFSImage    = "ipfs/kubo:release"
+	DefaultClusterImage = "ipfs/ipfs-cluster:latest"
+	DefaultStorageClass = "standard"
+	DefaultStorageSize  = "30Gi"
+)
+
+// Workload parameterises the images, volumes, container resources and external
+// service of a deploy. Empty fields fall back to the defaults above; an empty
+// ServiceType is derived from DeployConfig.Private.
+type Workload struct {
+	IPFSImage          string
+	ClusterImage       string
+	StorageClass       string
+	IPFSStorageSize    string
+	ClusterStorageSize string
+	ServiceType        corev1.ServiceType
+	IPFSResources      Resources
+	ClusterResources   Resources
+}
+
+// Resources are the CPU and memory requests and limits of a container as
+// Kubernetes quantities ("500m", "2Gi"). Empty fields are left unset.
+type Resources struct {
+	CPURequest    string
+	CPULimit      string
+	MemoryRequest string
+	MemoryLimit   string
+}
+
+// DefaultWorkload returns the built-in workload defaults. The service type is
+// the one of a public deploy.
+func DefaultWorkload() Workload {
+	return Workload{
+		IPFSImage:          DefaultIPFSImage,
+		ClusterImage:       DefaultClusterImage,
+		StorageClass:       DefaultStorageClass,
+		IPFSStorageSize:    DefaultStorageSize,
+		ClusterStorageSize: DefaultStorageSize,
+		ServiceType:        corev1.ServiceTypeLoadBalancer,
+	}
+}
+
+// Or returns w with its empty fields taken from fallback.
+func (w Workload) Or(fallback Workload) Workload {
+	w.IPFSImage = cmp.Or(w.IPFSImage, fallback.IPFSImage)
+	w.ClusterImage = cmp.Or(w.ClusterImage, fallback.ClusterImage)
+	w.StorageClass = cmp.Or(w.StorageClass, fallback.StorageClass)
+	w.IPFSStorageSize = cmp.Or(w.IPFSStorageSize, fallback.IPFSStorageSize)
+	w.ClusterStorageSize = cmp.Or(w.ClusterStorageSize, fallback.ClusterStorageSize)
+	w.ServiceType = cmp.Or(w.ServiceType, fallback.ServiceType)
+	w.IPFSResources = w.IPFSResources.or(fallback.IPFSResources)
+	w.ClusterResources = w.ClusterResources.or(fallback.ClusterResources)
+	return w
+}
+
+// Validate checks the fields that are set: storage sizes and resources must be
+// valid quantities, the service type one Deploy supports.
+func (w Workload) Validate() error {
+	for _, size := range []string{w.IPFSStorageSize, w.ClusterStorageSize} {
+		if size == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(size)
+		if err != nil {
+			return fmt.Errorf("invalid storage size %q: %w", size, err)
+		}
+		if q.Sign() <= 0 {
+			return fmt.Errorf("invalid storage size %q: must be positive", size)
+		}
+	}
+	switch w.ServiceType {
+	case "", corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("unsupported service type %q", w.ServiceType)
+	}
+	if err := w.IPFSResources.validate("ipfs"); err != nil {
+		return err
+	}
+	return w.ClusterResources.validate("ipfs-cluster")
+}
+
+// resolve fills in defaults and validates the workload parameters.
+func (w Workload) resolve(private bool) (Workload, error) {
+	if w.ServiceType == "" && private {
+		w.ServiceType = corev1.ServiceTypeClusterIP
+	}
+	w = w.Or(DefaultWorkload())
+	return w, w.Validate()
+}
+
+func (r Resources) or(fallback Resources) Resources {
+	r.CPURequest = cmp.Or(r.CPURequest, fallback.CPURequest)
+	r.CPULimit = cmp.Or(r.CPULimit, fallback.CPULimit)
+	r.MemoryRequest = cmp.Or(r.MemoryRequest, fallback.MemoryRequest)
+	r.MemoryLimit = cmp.Or(r.MemoryLimit, fallback.MemoryLimit)
+	return r
+}
+
+func (r Resources) validate(container string) error {
+	pairs := []struct {
+		name           string
+		request, limit string
+	}{
+		{"cpu", r.CPURequest, r.CPULimit},
+		{"memory", r.MemoryRequest, r.MemoryLimit},
+	}
+	for _, p := range pairs {
+		var request, limit resource.Quantity
+		for _, v := range []struct {
+			kind  string
+			value string
+			dst   *resource.Quantity
+		}{{"request", p.request, &request}, {"limit", p.limit, &limit}} {
+			if v.value == "" {
+				continue
+			}
+			q, err := resource.ParseQuantity(v.value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %s %s %q: %w", container, p.name, v.kind, v.value, err)
+			}
+			if q.Sign() < 0 {
+				return fmt.Errorf("invalid %s %s %s %q: must not be negative", container, p.name, v.kind, v.value)
+			}
+			*v.dst = q
+		}
+		if p.request != "" && p.limit != "" && request.Cmp(limit) > 0 {
+			return fmt.Errorf("%s %s request %s exceeds its limit %s", container, p.name, p.request, p.limit)
+		}
+	}
+	return nil
+}
+
+// requirements converts validated resources into container requirements.
+func (r Resources) requirements() corev1.ResourceRequirements {
+	var req corev1.ResourceRequirements
+	set := func(list *corev1.ResourceList, name corev1.ResourceName, value string) {
+		if value == "" {
+			return
+		}
+		if *list == nil {
+			*list = corev1.ResourceList{}
+		}
+		(*list)[name] = resource.MustParse(value)
+	}
+	set(&req.Requests, corev1.ResourceCPU, r.CPURequest)
+	set(&req.Requests, corev1.ResourceMemory, r.MemoryRequest)
+	set(&req.Limits, corev1.ResourceCPU, r.CPULimit)
+	set(&req.Limits, corev1.ResourceMemory, r.MemoryLimit)
+	return req
+}
@@ -49,3 +49,27 @@ func GenerateBootstrapPrivateKey() (*BootstrapKeyPair, error) {
 		PeerID:     peerID.String(),
 	}, nil
 }
+
+// KeyPairFromPrivateKey restores a key pair from a base64 encoded private key
+// produced by GenerateBootstrapPrivateKey.
+func KeyPairFromPrivateKey(privateKey string) (*BootstrapKeyPair, error) {
+	privBytes, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := libp2pcrypto.UnmarshalPrivateKey(privBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	peerID, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BootstrapKeyPair{
+		PrivateKey: privateKey,
+		PeerID:     peerID.String(),
+	}, nil
+}
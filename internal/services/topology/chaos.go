@@ -0,0 +1,314 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"github.com/google/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	ChaosRunning  = "running"
+	ChaosReverted = "reverted"
+	ChaosFailed   = "failed"
+
+	MaxChaosDuration = time.Hour
+
+	// chaosInjectTimeout leaves fill-disk time to write the volume full where
+	// the filesystem cannot preallocate.
+	chaosInjectTimeout = 5 * time.Minute
+	chaosRevertTimeout = time.Minute
+	// chaosReapplyInterval is how often a killed node's pod is deleted again, and
+	// an isolated node's pod labelled again, once its StatefulSet has recreated it.
+	chaosReapplyInterval = 2 * time.Second
+)
+
+var errChaosStopped = errors.New("stopped by user")
+
+// ErrChaosRunning is returned when a node already has a running chaos experiment.
+var ErrChaosRunning = errors.New("a chaos experiment is already running on the node")
+
+// ErrChaosNotRunning is returned when stopping a chaos experiment that has already finished.
+var ErrChaosNotRunning = errors.New("chaos experiment is not running")
+
+type chaosJob struct {
+	experimentID string
+	cancel       context.CancelCauseFunc
+	done         chan struct{}
+}
+
+// chaosJobs holds the running chaos experiments by topology and node ID; a node
+// has at most one.
+var chaosJobs = struct {
+	sync.Mutex
+	byNode map[[2]string]*chaosJob
+}{byNode: map[[2]string]*chaosJob{}}
+
+// StartChaos injects a fault into the pod of a deployed node and starts a worker
+// that reverts it after the requested duration.
+func StartChaos(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, restCfg *rest.Config, id, nodeID string, req ChaosRequest) (*ChaosExperiment, error) {
+	if !kubetopo.IsChaosAction(req.Action) {
+		return nil, &ClusterRequestError{Message: fmt.Sprintf("unknown chaos action %q (expected one of %s)",
+			req.Action, strings.Join(kubetopo.ChaosActions, ", "))}
+	}
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if duration <= 0 || duration > MaxChaosDuration {
+		return nil, &ClusterRequestError{Message: fmt.Sprintf("durationSeconds must be between 1 and %d", int(MaxChaosDuration.Seconds()))}
+	}
+	d, err := loadDeployedTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	podName, ok := d.PodByNode[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNodeNotDeployed, nodeID)
+	}
+
+	key := [2]string{id, nodeID}
+	jobCtx, cancel := context.WithCancelCause(context.Background())
+	job := &chaosJob{experimentID: uuid.NewString(), cancel: cancel, done: make(chan struct{})}
+	chaosJobs.Lock()
+	if chaosJobs.byNode[key] != nil {
+		chaosJobs.Unlock()
+		return nil, ErrChaosRunning
+	}
+	chaosJobs.byNode[key] = job
+	chaosJobs.Unlock()
+	release := func() {
+		chaosJobs.Lock()
+		if chaosJobs.byNode[key] == job {
+			delete(chaosJobs.byNode, key)
+		}
+		chaosJobs.Unlock()
+		cancel(nil)
+		close(job.done)
+	}
+
+	m := &topologymodels.TopologyChaosExperimentModel{
+		ExperimentID:    job.experimentID,
+		TopologyID:      id,
+		NodeID:          nodeID,
+		Namespace:       d.Namespace,
+		PodName:         podName,
+		Action:          req.Action,
+		DurationSeconds: req.DurationSeconds,
+		Status:          ChaosRunning,
+	}
+	if err := topologymodels.InsertTopologyChaosExperiment(ctx, db, m); err != nil {
+		release()
+		return nil, err
+	}
+
+	w := &chaosWorker{db: db, k8s: k8s, restCfg: restCfg, m: m}
+	// A client hanging up must not cut an injection short: fill-disk in
+	// particular runs for minutes.
+	injectCtx, cancelInject := context.WithTimeout(context.WithoutCancel(ctx), chaosInjectTimeout)
+	err = kubetopo.InjectChaos(injectCtx, k8s, restCfg, w.target(), req.Action)
+	cancelInject()
+	if err != nil {
+		err = fmt.Errorf("inject %s into pod %s: %w", req.Action, podName, err)
+		w.abort(err)
+		release()
+		return nil, err
+	}
+	slog.Info("chaos experiment started", "experimentId", m.ExperimentID, "topologyId", id, "nodeId", nodeID, "action", req.Action)
+
+	go func() {
+		defer release()
+		w.run(jobCtx, duration)
+	}()
+	return chaosExperimentFromModel(m), nil
+}
+
+// StopChaosExperiment reverts a running chaos experiment before its duration is over.
+func StopChaosExperiment(ctx context.Context, db *sql.DB, id string) (*ChaosExperiment, error) {
+	m, err := topologymodels.GetTopologyChaosExperimentByID(ctx, db, id)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	chaosJobs.Lock()
+	job := chaosJobs.byNode[[2]string{m.TopologyID, m.NodeID}]
+	chaosJobs.Unlock()
+	if job == nil || job.experimentID != id {
+		return nil, ErrChaosNotRunning
+	}
+	job.cancel(errChaosStopped)
+	<-job.done
+	return GetChaosExperiment(ctx, db, id)
+}
+
+// stopChaos reverts the running chaos experiments of a topology and waits for
+// their workers to exit.
+func stopChaos(topologyID string, cause error) {
+	chaosJobs.Lock()
+	var jobs []*chaosJob
+	for key, job := range chaosJobs.byNode {
+		if key[0] == topologyID {
+			jobs = append(jobs, job)
+		}
+	}
+	chaosJobs.Unlock()
+	for _, job := range jobs {
+		job.cancel(cause)
+		<-job.done
+	}
+}
+
+// GetChaosExperiment returns a chaos experiment, or nil if it does not exist.
+func GetChaosExperiment(ctx context.Context, db *sql.DB, id string) (*ChaosExperiment, error) {
+	m, err := topologymodels.GetTopologyChaosExperimentByID(ctx, db, id)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return chaosExperimentFromModel(m), nil
+}
+
+// ListChaosExperiments returns the chaos experiments of a topology, newest
+// first, or nil if the topology does not exist.
+func ListChaosExperiments(ctx context.Context, db *sql.DB, id string) ([]ChaosExperiment, error) {
+	t, err := topologymodels.GetTopologyByID(ctx, db, id)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	rows, err := topologymodels.GetTopologyChaosExperiments(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ChaosExperiment, 0, len(rows))
+	for i := range rows {
+		list = append(list, *chaosExperimentFromModel(&rows[i]))
+	}
+	return list, nil
+}
+
+// RevertInterruptedChaos reverts the experiments whose worker did not survive a
+// restart of the server, whether or not their duration is over.
+func RevertInterruptedChaos(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, restCfg *rest.Config) error {
+	rows, err := topologymodels.GetUnfinishedTopologyChaosExperiments(ctx, db)
+	if err != nil {
+		return err
+	}
+	for i := range rows {
+		w := &chaosWorker{db: db, k8s: k8s, restCfg: restCfg, m: &rows[i]}
+		w.finish(errors.New("interrupted by server restart"))
+	}
+	return nil
+}
+
+func chaosExperimentFromModel(m *topologymodels.TopologyChaosExperimentModel) *ChaosExperiment {
+	e := &ChaosExperiment{
+		ExperimentID:    m.ExperimentID,
+		TopologyID:      m.TopologyID,
+		NodeID:          m.NodeID,
+		Namespace:       m.Namespace,
+		PodName:         m.PodName,
+		Action:          m.Action,
+		DurationSeconds: m.DurationSeconds,
+		Status:          m.Status,
+		Message:         m.Message,
+		StartedAt:       m.StartedAt.Format("2006-01-02T15:04:05Z07:00"),
+		EndsAt:          m.EndsAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if m.FinishedAt != nil {
+		finished := m.FinishedAt.Format("2006-01-02T15:04:05Z07:00")
+		e.FinishedAt = &finished
+	}
+	return e
+}
+
+// chaosWorker holds a fault for the duration of one experiment and reverts it.
+type chaosWorker struct {
+	db      *sql.DB
+	k8s     kubernetes.Interface
+	restCfg *rest.Config
+	m       *topologymodels.TopologyChaosExperimentModel
+}
+
+func (w *chaosWorker) target() kubetopo.ChaosTarget {
+	return kubetopo.ChaosTarget{TopologyID: w.m.TopologyID, Namespace: w.m.Namespace, PodName: w.m.PodName}
+}
+
+// run waits for the duration of the experiment, deleting the pod of a killed
+// node and labelling the pod of an isolated node again whenever it comes back,
+// then reverts the fault.
+func (w *chaosWorker) run(ctx context.Context, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	var tick <-chan time.Time
+	if w.m.Action == kubetopo.ChaosKill || w.m.Action == kubetopo.ChaosIsolate {
+		ticker := time.NewTicker(chaosReapplyInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			w.finish(context.Cause(ctx))
+			return
+		case <-timer.C:
+			w.finish(nil)
+			return
+		case <-tick:
+			w.reapply(ctx)
+		}
+	}
+}
+
+// reapply holds a kill or an isolation on a pod its StatefulSet may have recreated.
+func (w *chaosWorker) reapply(ctx context.Context) {
+	var err error
+	switch w.m.Action {
+	case kubetopo.ChaosKill:
+		err = kubetopo.KillPod(ctx, w.k8s, w.target())
+	case kubetopo.ChaosIsolate:
+		err = kubetopo.LabelIsolated(ctx, w.k8s, w.target())
+	}
+	if err != nil && ctx.Err() == nil {
+		slog.Error("reapply chaos", "experimentId", w.m.ExperimentID, "action", w.m.Action, "error", err)
+	}
+}
+
+// finish reverts the fault and records the outcome: reverted, with the reason
+// if the experiment ended early, or failed if the fault could not be reverted.
+func (w *chaosWorker) finish(cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chaosRevertTimeout)
+	defer cancel()
+	status, message := ChaosReverted, fmt.Sprintf("reverted after %ds", w.m.DurationSeconds)
+	if cause != nil {
+		message = fmt.Sprintf("%s: reverted", cause)
+	}
+	if err := kubetopo.RevertChaos(ctx, w.k8s, w.restCfg, w.target(), w.m.Action); err != nil {
+		status, message = ChaosFailed, fmt.Sprintf("revert %s: %v", w.m.Action, err)
+		if cause != nil {
+			message = fmt.Sprintf("%s; %s", cause, message)
+		}
+	}
+	slog.Info("chaos experiment finished", "experimentId", w.m.ExperimentID, "status", status, "message", message)
+	if err := topologymodels.FinishTopologyChaosExperiment(ctx, w.db, w.m.ExperimentID, status, message); err != nil {
+		slog.Error("FinishTopologyChaosExperiment", "error", err)
+	}
+}
+
+// abort undoes whatever part of a fault was injected before the injection
+// failed and records the experiment as failed.
+func (w *chaosWorker) abort(cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chaosRevertTimeout)
+	defer cancel()
+	if err := kubetopo.RevertChaos(ctx, w.k8s, w.restCfg, w.target(), w.m.Action); err != nil {
+		slog.Error("RevertChaos", "experimentId", w.m.ExperimentID, "error", err)
+	}
+	if err := topologymodels.FinishTopologyChaosExperiment(ctx, w.db, w.m.ExperimentID, ChaosFailed, cause.Error()); err != nil {
+		slog.Error("FinishTopologyChaosExperiment", "error", err)
+	}
+}
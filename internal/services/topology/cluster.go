@@ -0,0 +1,160 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// nodeAPITimeout bounds one call to a pod API so a hung node does not stall the
+// whole topology query.
+const nodeAPITimeout = 10 * time.Second
+
+// deployedTopology is a topology together with the pods of its latest deploy.
+type deployedTopology struct {
+	*Topology
+	Namespace string
+	Pods      []topologymodels.TopologyNodePodModel
+	PodByNode map[string]string
+}
+
+func loadDeployedTopology(ctx context.Context, db *sql.DB, id string) (*deployedTopology, error) {
+	t, err := GetTopologyByID(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTopologyNotFound, id)
+	}
+	pods, err := topologymodels.GetNodePodsByTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 || t.K8sNamespace == nil {
+		return nil, ErrTopologyNotDeployed
+	}
+	d := &deployedTopology{Topology: t, Namespace: *t.K8sNamespace, Pods: pods, PodByNode: make(map[string]string, len(pods))}
+	for _, p := range pods {
+		d.PodByNode[p.NodeID] = p.PodName
+	}
+	return d, nil
+}
+
+// apiPod returns the pod of a deployed node, or the first pod if nodeID is empty.
+func (d *deployedTopology) apiPod(nodeID string) (string, error) {
+	if nodeID == "" {
+		return d.Pods[0].PodName, nil
+	}
+	podName, ok := d.PodByNode[nodeID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNodeNotDeployed, nodeID)
+	}
+	return podName, nil
+}
+
+// peerNodes maps the ipfs-cluster and kubo peer IDs of a topology to canvas nodes.
+func peerNodes(ctx context.Context, db *sql.DB, id string) (map[string]string, map[string]string, error) {
+	rows, err := topologymodels.GetNodeIdentitiesByTopology(ctx, db, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	byClusterPeer := make(map[string]string, len(rows))
+	byIPFSPeer := make(map[string]string, len(rows))
+	for _, r := range rows {
+		byClusterPeer[r.PeerID] = r.NodeID
+		byIPFSPeer[r.IPFSPeerID] = r.NodeID
+	}
+	return byClusterPeer, byIPFSPeer, nil
+}
+
+// eachPod runs fn for every deployed pod in parallel, each call with its own
+// timeout, and waits for all of them.
+func eachPod(ctx context.Context, pods []topologymodels.TopologyNodePodModel, fn func(ctx context.Context, i int, pod topologymodels.TopologyNodePodModel)) {
+	var wg sync.WaitGroup
+	for i, p := range pods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, nodeAPITimeout)
+			defer cancel()
+			fn(callCtx, i, p)
+		}()
+	}
+	wg.Wait()
+}
+
+// GetClusterPeers asks the ipfs-cluster REST API of every deployed pod for its
+// identity and peerset, and checks that each peer sees the other peers of its
+// network.
+func GetClusterPeers(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id string) (*ClusterPeers, error) {
+	d, err := loadDeployedTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	nodeByPeer, _, err := peerNodes(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]ClusterPeer, len(d.Pods))
+	eachPod(ctx, d.Pods, func(ctx context.Context, i int, p topologymodels.TopologyNodePodModel) {
+		peers[i] = ClusterPeer{NodeID: p.NodeID, PodName: p.PodName, Peerset: []string{}}
+		cid, err := kubetopo.GetClusterID(ctx, k8s, d.Namespace, p.PodName)
+		if err != nil {
+			peers[i].Error = err.Error()
+			return
+		}
+		peers[i].Reachable = true
+		peers[i].PeerID = cid.ID
+		peers[i].PeerName = cid.PeerName
+		peers[i].Version = cid.Version
+		peers[i].Addresses = cid.Addresses
+		peers[i].Error = cid.Error
+		peers[i].IPFSPeerID = cid.IPFS.ID
+		peers[i].IPFSError = cid.IPFS.Error
+		for _, peerID := range cid.ClusterPeers {
+			if nodeID, ok := nodeByPeer[peerID]; ok {
+				peerID = nodeID
+			}
+			peers[i].Peerset = append(peers[i].Peerset, peerID)
+		}
+	})
+
+	// A peer is expected to see every other deployed node of its network.
+	network := map[string][]string{}
+	for _, c := range components(d.Topology) {
+		for _, nodeID := range c {
+			network[nodeID] = c
+		}
+	}
+	result := &ClusterPeers{TopologyID: id, Consensus: d.Settings.cluster().Or(kubetopo.DefaultClusterConfig()).Consensus, Formed: true, Peers: peers}
+	for i := range peers {
+		p := &peers[i]
+		if !p.Reachable || p.Error != "" {
+			result.Formed = false
+		}
+		if !p.Reachable {
+			continue
+		}
+		seen := make(map[string]bool, len(p.Peerset))
+		for _, nodeID := range p.Peerset {
+			seen[nodeID] = true
+		}
+		for _, nodeID := range network[p.NodeID] {
+			if _, deployed := d.PodByNode[nodeID]; deployed && !seen[nodeID] {
+				p.MissingPeers = append(p.MissingPeers, nodeID)
+			}
+		}
+		if len(p.MissingPeers) > 0 {
+			result.Formed = false
+		}
+	}
+	return result, nil
+}
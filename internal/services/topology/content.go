@@ -0,0 +1,89 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// AddContent streams a multipart upload into the cluster /add endpoint of one
+// node (the first deployed node if nodeID is empty). The content is imported by
+// that node's kubo and pinned in the cluster.
+func AddContent(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id, nodeID, contentType string, body io.Reader, opts kubetopo.AddOptions) (*AddResult, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "multipart/form-data" {
+		return nil, &ClusterRequestError{Message: "expected a multipart/form-data body"}
+	}
+	if opts.CIDVersion != 0 && opts.CIDVersion != 1 {
+		return nil, &ClusterRequestError{Message: fmt.Sprintf("unsupported cid version %d", opts.CIDVersion)}
+	}
+	d, podName, nodeByPeer, err := clusterTarget(ctx, db, id, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	added, err := kubetopo.Add(ctx, k8s, d.Namespace, podName, contentType, body, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(added) == 0 {
+		return nil, &ClusterRequestError{Message: "the upload contained no files"}
+	}
+
+	result := &AddResult{NodeID: nodeID, PodName: podName, Entries: make([]AddedEntry, 0, len(added))}
+	if result.NodeID == "" {
+		result.NodeID = d.Pods[0].NodeID
+	}
+	for _, a := range added {
+		result.Entries = append(result.Entries, AddedEntry{
+			Name:        a.Name,
+			CID:         string(a.CID),
+			Size:        a.Size,
+			Allocations: peersToNodes(a.Allocations, nodeByPeer),
+		})
+	}
+	root := result.Entries[len(result.Entries)-1]
+	result.CID, result.Allocations = root.CID, root.Allocations
+	return result, nil
+}
+
+// FetchContent requests /ipfs/<cid>/<subPath> from the HTTP gateway of one node
+// through the API server. TTFB is the time until the response headers arrived.
+// The caller streams and closes Response.Body.
+func FetchContent(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id, nodeID, c, subPath, method string, query url.Values, header http.Header) (*GatewayResponse, error) {
+	if err := validateCID(c); err != nil {
+		return nil, err
+	}
+	d, err := loadDeployedTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	podName, ok := d.PodByNode[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNodeNotDeployed, nodeID)
+	}
+
+	p := "/ipfs/" + c
+	if subPath != "" {
+		p += "/" + strings.TrimPrefix(subPath, "/")
+	}
+	start := time.Now()
+	resp, err := kubetopo.ProxyPod(ctx, k8s, d.Namespace, podName, kubetopo.PortGateway, kubetopo.ProxyRequest{
+		Method: method,
+		Path:   p,
+		Query:  query,
+		Header: header,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GatewayResponse{NodeID: nodeID, PodName: podName, TTFB: time.Since(start), Response: resp}, nil
+}
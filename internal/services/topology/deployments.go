@@ -0,0 +1,331 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"github.com/google/uuid"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DeploymentDeploying = "deploying"
+	DeploymentRunning   = "running"
+	DeploymentDegraded  = "degraded"
+	DeploymentError     = "error"
+	DeploymentCancelled = "cancelled"
+
+	DefaultDeployTimeout = 15 * time.Minute
+	rolloutPollInterval  = 5 * time.Second
+)
+
+var (
+	errDeploymentCancelled = errors.New("cancelled by user")
+	errTopologyUndeployed  = errors.New("topology was undeployed")
+	errDeploymentTimeout   = errors.New("timed out waiting for pods")
+)
+
+// ErrDeploymentSuperseded is returned when a newer deploy of the topology took
+// over before this one could start.
+var ErrDeploymentSuperseded = errors.New("superseded by a newer deployment")
+
+// ErrDeploymentNotRunning is returned when cancelling a deployment that has already finished.
+var ErrDeploymentNotRunning = errors.New("deployment is not running")
+
+type deployJob struct {
+	deploymentID string
+	cancel       context.CancelCauseFunc
+	done         chan struct{}
+}
+
+// deployJobs holds the running deploy workers by topology ID; a topology has at
+// most one.
+var deployJobs = struct {
+	sync.Mutex
+	byTopology map[string]*deployJob
+}{byTopology: map[string]*deployJob{}}
+
+// startDeployment records a new deployment, applies the topology objects and
+// starts a worker that follows the rollout. A deployment still running for the
+// topology is cancelled first. The apply runs under the job context, so a client
+// hanging up does not leave the topology half applied.
+func startDeployment(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, cfg kubetopo.DeployConfig, timeout time.Duration) (*topologymodels.TopologyDeploymentModel, []kubetopo.ObjectChange, error) {
+	jobCtx, cancel := context.WithCancelCause(context.Background())
+	job := &deployJob{deploymentID: uuid.NewString(), cancel: cancel, done: make(chan struct{})}
+
+	// Taking the topology over and cancelling its running deployment happen in
+	// one critical section, so of concurrent deploys the last one to get here
+	// wins and each waits for the one it superseded.
+	deployJobs.Lock()
+	prev := deployJobs.byTopology[cfg.TopologyID]
+	deployJobs.byTopology[cfg.TopologyID] = job
+	if prev != nil {
+		prev.cancel(ErrDeploymentSuperseded)
+	}
+	deployJobs.Unlock()
+	release := func() {
+		deployJobs.Lock()
+		if deployJobs.byTopology[cfg.TopologyID] == job {
+			delete(deployJobs.byTopology, cfg.TopologyID)
+		}
+		deployJobs.Unlock()
+		cancel(nil)
+		close(job.done)
+	}
+	if prev != nil {
+		<-prev.done
+	}
+	if err := context.Cause(jobCtx); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	m := &topologymodels.TopologyDeploymentModel{
+		DeploymentID: job.deploymentID,
+		TopologyID:   cfg.TopologyID,
+		Namespace:    cfg.Namespace,
+		Status:       DeploymentDeploying,
+		Steps:        []topologymodels.DeploymentStepModel{},
+	}
+	if err := topologymodels.InsertTopologyDeployment(ctx, db, m); err != nil {
+		release()
+		return nil, nil, err
+	}
+	namespace := cfg.Namespace
+	if err := topologymodels.UpdateTopologyDeployStatus(ctx, db, cfg.TopologyID, "deploying", &namespace); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultDeployTimeout
+	}
+	w := &deployWorker{db: db, k8s: k8s, cfg: cfg, deploymentID: m.DeploymentID, timeout: timeout}
+	changes, nodeByPod, err := w.apply(jobCtx)
+	if err != nil {
+		w.finish(jobCtx, nil, err)
+		release()
+		return nil, nil, &DeploymentFailedError{DeploymentID: m.DeploymentID, Err: err}
+	}
+
+	waitCtx, cancelTimeout := context.WithTimeoutCause(jobCtx, timeout, errDeploymentTimeout)
+	go func() {
+		defer release()
+		defer cancelTimeout()
+		w.wait(waitCtx, nodeByPod)
+	}()
+	return m, changes, nil
+}
+
+// stopDeployment cancels the running deployment of a topology, if any, and waits
+// for its worker to exit.
+func stopDeployment(topologyID string, cause error) {
+	deployJobs.Lock()
+	job := deployJobs.byTopology[topologyID]
+	deployJobs.Unlock()
+	if job == nil {
+		return
+	}
+	job.cancel(cause)
+	<-job.done
+}
+
+// CancelDeployment stops a running deployment. Objects already applied are left in place.
+func CancelDeployment(ctx context.Context, db *sql.DB, id string) (*Deployment, error) {
+	m, err := topologymodels.GetTopologyDeploymentByID(ctx, db, id)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	deployJobs.Lock()
+	job := deployJobs.byTopology[m.TopologyID]
+	deployJobs.Unlock()
+	if job == nil || job.deploymentID != id {
+		return nil, ErrDeploymentNotRunning
+	}
+	job.cancel(errDeploymentCancelled)
+	<-job.done
+	return GetDeployment(ctx, db, id)
+}
+
+// GetDeployment returns a deployment with its step log, or nil if it does not exist.
+func GetDeployment(ctx context.Context, db *sql.DB, id string) (*Deployment, error) {
+	m, err := topologymodels.GetTopologyDeploymentByID(ctx, db, id)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	return deploymentFromModel(m), nil
+}
+
+func deploymentFromModel(m *topologymodels.TopologyDeploymentModel) *Deployment {
+	d := &Deployment{
+		DeploymentID: m.DeploymentID,
+		TopologyID:   m.TopologyID,
+		Namespace:    m.Namespace,
+		Status:       m.Status,
+		Message:      m.Message,
+		Steps:        make([]DeploymentStep, 0, len(m.Steps)),
+		CreatedAt:    m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:    m.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if m.FinishedAt != nil {
+		finished := m.FinishedAt.Format("2006-01-02T15:04:05Z07:00")
+		d.FinishedAt = &finished
+	}
+	for _, s := range m.Steps {
+		d.Steps = append(d.Steps, DeploymentStep{Time: s.Time.Format("2006-01-02T15:04:05Z07:00"), Message: s.Message})
+	}
+	return d
+}
+
+// deployWorker drives one deployment and writes its step log.
+type deployWorker struct {
+	db           *sql.DB
+	k8s          kubernetes.Interface
+	cfg          kubetopo.DeployConfig
+	deploymentID string
+	timeout      time.Duration
+}
+
+// apply server-side applies the topology objects and stores the pod assignment.
+func (w *deployWorker) apply(ctx context.Context) ([]kubetopo.ObjectChange, map[string]string, error) {
+	w.step("applying objects to namespace %s", w.cfg.Namespace)
+	assignments, changes, err := kubetopo.Deploy(ctx, w.k8s, w.cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("apply objects: %w", err)
+	}
+	counts := map[string]int{}
+	for _, c := range changes {
+		counts[c.Action]++
+	}
+	w.step("objects applied: %d created, %d updated, %d unchanged, %d deleted",
+		counts[kubetopo.ActionCreated], counts[kubetopo.ActionUpdated], counts[kubetopo.ActionUnchanged], counts[kubetopo.ActionDeleted])
+
+	podModels := make([]topologymodels.TopologyNodePodModel, 0, len(assignments))
+	nodeByPod := make(map[string]string, len(assignments))
+	for _, a := range assignments {
+		podModels = append(podModels, topologymodels.TopologyNodePodModel{
+			TopologyID: w.cfg.TopologyID,
+			NodeID:     a.NodeID,
+			PodName:    a.PodName,
+			Workload:   a.Workload,
+			Ordinal:    a.Ordinal,
+		})
+		nodeByPod[a.PodName] = a.NodeID
+	}
+	if err := topologymodels.ReplaceTopologyNodePods(ctx, w.db, w.cfg.TopologyID, podModels); err != nil {
+		return nil, nil, fmt.Errorf("save pod assignment: %w", err)
+	}
+	return changes, nodeByPod, nil
+}
+
+// wait follows the rollout until every pod is ready, the deployment times out or
+// it is cancelled.
+func (w *deployWorker) wait(ctx context.Context, nodeByPod map[string]string) {
+	w.step("waiting for %d pods to become ready", len(nodeByPod))
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+	var last *kubetopo.RolloutStatus
+	reasons := map[string]string{}
+	lastErr := ""
+	for {
+		status, err := kubetopo.GetRolloutStatus(ctx, w.k8s, w.cfg.TopologyID, w.cfg.Namespace)
+		if err != nil && ctx.Err() == nil && err.Error() != lastErr {
+			lastErr = err.Error()
+			w.step("read rollout status: %v", err)
+		}
+		if err == nil {
+			lastErr = ""
+			if last == nil || status.Ready != last.Ready {
+				w.step("%d/%d pods ready", status.Ready, status.Replicas)
+			}
+			for _, p := range status.Pods {
+				if p.Reason != reasons[p.PodName] && p.Reason != "" {
+					w.step("pod %s (node %s): %s", p.PodName, nodeByPod[p.PodName], p.Reason)
+				}
+				reasons[p.PodName] = p.Reason
+			}
+			last = status
+			if status.Done() {
+				w.finish(ctx, last, nil)
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			w.finish(ctx, last, context.Cause(ctx))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// finish records the final status of the deployment and of the topology.
+func (w *deployWorker) finish(ctx context.Context, last *kubetopo.RolloutStatus, cause error) {
+	if cause != nil && ctx.Err() != nil {
+		cause = context.Cause(ctx)
+	}
+	status, message := DeploymentRunning, "all pods ready"
+	switch {
+	case cause == nil && last != nil:
+		message = fmt.Sprintf("all %d pods ready", last.Replicas)
+	case errors.Is(cause, errDeploymentCancelled), errors.Is(cause, ErrDeploymentSuperseded), errors.Is(cause, errTopologyUndeployed):
+		status, message = DeploymentCancelled, cause.Error()
+	case errors.Is(cause, errDeploymentTimeout):
+		status = rolloutOutcome(last)
+		message = fmt.Sprintf("%s after %s", cause, w.timeout)
+		if last != nil {
+			message += fmt.Sprintf(": %d/%d pods ready", last.Ready, last.Replicas)
+		}
+	default:
+		status, message = DeploymentError, cause.Error()
+	}
+	w.step("%s: %s", status, message)
+
+	bg := context.Background()
+	if err := topologymodels.FinishTopologyDeployment(bg, w.db, w.deploymentID, status, message); err != nil {
+		slog.Error("FinishTopologyDeployment", "error", err)
+	}
+
+	// A superseding deploy or an undeploy owns the topology status from now on.
+	if errors.Is(cause, ErrDeploymentSuperseded) || errors.Is(cause, errTopologyUndeployed) {
+		return
+	}
+	topologyStatus := status
+	if status == DeploymentCancelled {
+		topologyStatus = rolloutOutcome(last)
+	}
+	namespace := w.cfg.Namespace
+	if err := topologymodels.UpdateTopologyDeployStatus(bg, w.db, w.cfg.TopologyID, topologyStatus, &namespace); err != nil {
+		slog.Error("UpdateTopologyDeployStatus", "error", err)
+	}
+}
+
+// rolloutOutcome classifies an unfinished rollout: degraded if some pods are ready.
+func rolloutOutcome(last *kubetopo.RolloutStatus) string {
+	switch {
+	case last == nil || last.Ready == 0:
+		return DeploymentError
+	case last.Done():
+		return DeploymentRunning
+	default:
+		return DeploymentDegraded
+	}
+}
+
+func (w *deployWorker) step(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	slog.Info("deployment step", "deploymentId", w.deploymentID, "topologyId", w.cfg.TopologyID, "step", msg)
+	step := topologymodels.DeploymentStepModel{Time: time.Now(), Message: msg}
+	if err := topologymodels.AppendTopologyDeploymentStep(context.Background(), w.db, w.deploymentID, step); err != nil {
+		slog.Error("AppendTopologyDeploymentStep", "error", err)
+	}
+}
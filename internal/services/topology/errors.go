@@ -0,0 +1,84 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTopologyNotFound is returned by queries against the running nodes of a
+// topology that does not exist.
+var ErrTopologyNotFound = errors.New("topology not found")
+
+// ErrTopologyNotDeployed is returned by queries against the running nodes of a
+// topology that has no deployed pods.
+var ErrTopologyNotDeployed = errors.New("topology is not deployed")
+
+// ErrNodeNotDeployed is returned when a request names a node that has no pod.
+var ErrNodeNotDeployed = errors.New("node is not deployed")
+
+// DeployRequestError is returned when a topology cannot be deployed, planned
+// or exported as requested: it has no nodes, or a deploy option is invalid.
+type DeployRequestError struct {
+	Message string
+}
+
+func (e *DeployRequestError) Error() string { return e.Message }
+
+// DeploymentFailedError is returned when a deployment was recorded but its
+// objects could not be applied. The deployment holds the step log.
+type DeploymentFailedError struct {
+	DeploymentID string
+	Err          error
+}
+
+func (e *DeploymentFailedError) Error() string { return e.Err.Error() }
+
+func (e *DeploymentFailedError) Unwrap() error { return e.Err }
+
+// ClusterRequestError is returned when a request to the running cluster (pins,
+// content) has invalid parameters.
+type ClusterRequestError struct {
+	Message string
+}
+
+func (e *ClusterRequestError) Error() string { return e.Message }
+
+// DisconnectedTopologyError is returned when a topology consists of several
+// sub-graphs and the deploy was not asked to run them as independent networks.
+type DisconnectedTopologyError struct {
+	Components [][]string // node labels (or IDs) per component
+}
+
+func (e *DisconnectedTopologyError) Error() string {
+	parts := make([]string, 0, len(e.Components))
+	for i, c := range e.Components {
+		parts = append(parts, fmt.Sprintf("component %d: [%s]", i+1, strings.Join(c, ", ")))
+	}
+	return fmt.Sprintf("topology has %d disconnected components (%s); connect them or deploy with disconnected=independent",
+		len(e.Components), strings.Join(parts, "; "))
+}
+
+// MissingBootstrapError is returned when a component has no node the others can bootstrap to.
+type MissingBootstrapError struct {
+	Component []string // node labels (or IDs)
+}
+
+func (e *MissingBootstrapError) Error() string {
+	return fmt.Sprintf("component [%s] has no bootstrap node (mark a node with role bootstrap or leave a node without outgoing edges)",
+		strings.Join(e.Component, ", "))
+}
+
+// InvalidTopologyError is returned by create/update when validation finds errors.
+type InvalidTopologyError struct {
+	Result ValidationResult
+}
+
+func (e *InvalidTopologyError) Error() string {
+	for _, i := range e.Result.Issues {
+		if i.Severity == SeverityError {
+			return "invalid topology: " + i.Message
+		}
+	}
+	return "invalid topology"
+}
@@ -0,0 +1,58 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetTopologyEvents returns the Kubernetes events of a deployed topology, newest
+// first. Events of pods and PVCs carry the canvas node of their pod.
+func GetTopologyEvents(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id string) ([]TopologyEvent, error) {
+	t, err := GetTopologyByID(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTopologyNotFound, id)
+	}
+	ns := "default"
+	if t.K8sNamespace != nil {
+		ns = *t.K8sNamespace
+	}
+	rows, err := topologymodels.GetNodePodsByTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	nodeByPod := make(map[string]string, len(rows))
+	pods := make([]kubetopo.PodAssignment, 0, len(rows))
+	for _, r := range rows {
+		nodeByPod[r.PodName] = r.NodeID
+		pods = append(pods, kubetopo.PodAssignment{NodeID: r.NodeID, PodName: r.PodName, Workload: r.Workload, Ordinal: r.Ordinal})
+	}
+	raw, err := kubetopo.ListEvents(ctx, k8s, id, ns, pods)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]TopologyEvent, 0, len(raw))
+	for _, e := range raw {
+		events = append(events, TopologyEvent{
+			NodeID:    nodeByPod[e.PodName],
+			Kind:      e.Kind,
+			Name:      e.Name,
+			PodName:   e.PodName,
+			Type:      e.Type,
+			Reason:    e.Reason,
+			Message:   e.Message,
+			Count:     e.Count,
+			FirstTime: e.FirstTime.Format("2006-01-02T15:04:05Z07:00"),
+			LastTime:  e.LastTime.Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,82 @@
+package topology
+
+import "sort"
+
+// components splits the topology into weakly connected sub-graphs. Edges pointing
+// at unknown nodes are ignored. Components and their node IDs are sorted for
+// stable output.
+func components(t *Topology) [][]string {
+	parent := make(map[string]string, len(t.Nodes))
+	for _, n := range t.Nodes {
+		parent[n.NodeID] = n.NodeID
+	}
+	var find func(string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	for _, e := range t.Edges {
+		if _, ok := parent[e.SourceNodeID]; !ok {
+			continue
+		}
+		if _, ok := parent[e.TargetNodeID]; !ok {
+			continue
+		}
+		a, b := find(e.SourceNodeID), find(e.TargetNodeID)
+		if a != b {
+			parent[a] = b
+		}
+	}
+
+	groups := make(map[string][]string)
+	for _, n := range t.Nodes {
+		root := find(n.NodeID)
+		groups[root] = append(groups[root], n.NodeID)
+	}
+	out := make([][]string, 0, len(groups))
+	for _, ids := range groups {
+		sort.Strings(ids)
+		out = append(out, ids)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
+
+// resolveBootstrapNodes returns the bootstrap nodes of a component: nodes with the
+// bootstrap role or, if there are none, the nodes others connect to that do not
+// connect anywhere themselves. A single-node component is its own bootstrap.
+func resolveBootstrapNodes(t *Topology, component []string) []string {
+	in := make(map[string]bool, len(component))
+	for _, id := range component {
+		in[id] = true
+	}
+	targets := make(map[string]bool)
+	sources := make(map[string]bool)
+	for _, e := range t.Edges {
+		if in[e.SourceNodeID] && in[e.TargetNodeID] {
+			targets[e.TargetNodeID] = true
+			sources[e.SourceNodeID] = true
+		}
+	}
+
+	var out []string
+	for _, n := range t.Nodes {
+		if in[n.NodeID] && n.Role == "bootstrap" {
+			out = append(out, n.NodeID)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for _, n := range t.Nodes {
+		if in[n.NodeID] && targets[n.NodeID] && !sources[n.NodeID] {
+			out = append(out, n.NodeID)
+		}
+	}
+	if len(out) == 0 && len(component) == 1 {
+		out = append(out, component[0])
+	}
+	return out
+}
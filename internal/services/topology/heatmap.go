@@ -0,0 +1,110 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Holding states of a CID on a node, from hottest to coldest.
+const (
+	HoldingPinned  = "pinned"  // pinned in kubo or by the cluster on this peer
+	HoldingPinning = "pinning" // the cluster is pinning it or has it queued
+	HoldingCached  = "cached"  // the whole DAG is in the local store, unpinned
+	HoldingPartial = "partial" // the root block is local, some blocks below are missing
+	HoldingAbsent  = "absent"
+	HoldingUnknown = "unknown" // the kubo API did not answer
+)
+
+// GetContentHeatmap reports for every deployed node whether it holds a CID: the
+// cluster pin status of its peer plus what its kubo pins and stores locally.
+// kubo is only asked offline, so the check does not fetch the content.
+func GetContentHeatmap(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id, c string) (*ContentHeatmap, error) {
+	if err := validateCID(c); err != nil {
+		return nil, err
+	}
+	d, err := loadDeployedTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	nodeByPeer, _, err := peerNodes(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ContentHeatmap{TopologyID: id, CID: c, Nodes: make([]NodeHolding, len(d.Pods))}
+	clusterStatus := map[string]string{}
+	info, err := kubetopo.GetPinStatus(ctx, k8s, d.Namespace, d.Pods[0].PodName, c)
+	var pe *kubetopo.ProxyError
+	switch {
+	case errors.As(err, &pe) && pe.StatusCode == http.StatusNotFound:
+	case err != nil:
+		result.ClusterError = err.Error()
+	default:
+		for peerID, p := range info.PeerMap {
+			if nodeID, ok := nodeByPeer[peerID]; ok {
+				clusterStatus[nodeID] = p.Status
+			}
+			if p.Status == "pinned" {
+				result.ClusterPinned = true
+			}
+		}
+	}
+
+	eachPod(ctx, d.Pods, func(ctx context.Context, i int, p topologymodels.TopologyNodePodModel) {
+		h := NodeHolding{NodeID: p.NodeID, PodName: p.PodName, ClusterStatus: clusterStatus[p.NodeID]}
+		defer func() { h.State = holdingState(h); result.Nodes[i] = h }()
+
+		pinType, err := kubetopo.GetPinType(ctx, k8s, d.Namespace, p.PodName, c)
+		if err != nil {
+			h.Error = err.Error()
+			return
+		}
+		h.KuboPin = pinType
+		size, found, err := kubetopo.GetLocalBlockSize(ctx, k8s, d.Namespace, p.PodName, c)
+		if err != nil {
+			h.Error = err.Error()
+			return
+		}
+		h.RootPresent, h.RootSize = found, size
+		if !found {
+			return
+		}
+		refs, complete, err := kubetopo.CountLocalRefs(ctx, k8s, d.Namespace, p.PodName, c)
+		if err != nil {
+			h.Error = err.Error()
+			return
+		}
+		h.Blocks, h.Complete = refs+1, complete
+	})
+
+	for _, n := range result.Nodes {
+		if n.State == HoldingPinned || n.State == HoldingCached {
+			result.Holders++
+		}
+	}
+	return result, nil
+}
+
+func holdingState(h NodeHolding) string {
+	switch {
+	case h.KuboPin != "" || h.ClusterStatus == "pinned":
+		return HoldingPinned
+	case h.ClusterStatus == "pinning" || h.ClusterStatus == "pin_queued":
+		return HoldingPinning
+	case h.RootPresent && h.Complete:
+		return HoldingCached
+	case h.RootPresent:
+		return HoldingPartial
+	case h.Error != "":
+		return HoldingUnknown
+	default:
+		return HoldingAbsent
+	}
+}
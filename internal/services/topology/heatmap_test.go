@@ -0,0 +1,31 @@
+package topology
+
+import "testing"
+
+func TestHoldingState(t *testing.T) {
+	tests := []struct {
+		name string
+		h    NodeHolding
+		want string
+	}{
+		{name: "recursive kubo pin", h: NodeHolding{KuboPin: "recursive"}, want: HoldingPinned},
+		{name: "indirect kubo pin", h: NodeHolding{KuboPin: "indirect", RootPresent: true}, want: HoldingPinned},
+		{name: "pinned by the cluster", h: NodeHolding{ClusterStatus: "pinned"}, want: HoldingPinned},
+		{name: "kubo pin wins over a cluster in progress", h: NodeHolding{KuboPin: "direct", ClusterStatus: "pinning"}, want: HoldingPinned},
+		{name: "cluster pinning", h: NodeHolding{ClusterStatus: "pinning", RootPresent: true}, want: HoldingPinning},
+		{name: "cluster pin queued", h: NodeHolding{ClusterStatus: "pin_queued"}, want: HoldingPinning},
+		{name: "whole DAG cached", h: NodeHolding{ClusterStatus: "unpinned", RootPresent: true, Complete: true}, want: HoldingCached},
+		{name: "root block only", h: NodeHolding{RootPresent: true}, want: HoldingPartial},
+		{name: "root present despite an error", h: NodeHolding{RootPresent: true, Error: "timeout"}, want: HoldingPartial},
+		{name: "kubo API did not answer", h: NodeHolding{Error: "connection refused"}, want: HoldingUnknown},
+		{name: "nothing local", h: NodeHolding{}, want: HoldingAbsent},
+		{name: "cluster error without local blocks", h: NodeHolding{ClusterStatus: "pin_error"}, want: HoldingAbsent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := holdingState(tt.h); got != tt.want {
+				t.Errorf("holdingState(%+v) = %q, want %q", tt.h, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"github.com/ipfs/go-cid"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AddPin pins a CID in the ipfs-cluster of a deployed topology. The request goes
+// through req.NodeID or, if empty, the first deployed node.
+func AddPin(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id string, req PinRequest) (*Pin, error) {
+	if err := validateCID(req.CID); err != nil {
+		return nil, err
+	}
+	if req.ReplicationMin < -1 || req.ReplicationMax < -1 {
+		return nil, &ClusterRequestError{Message: "replication factors must be -1, 0 or positive"}
+	}
+	if req.ReplicationMin > 0 && req.ReplicationMax > 0 && req.ReplicationMin > req.ReplicationMax {
+		return nil, &ClusterRequestError{Message: "replicationMin is greater than replicationMax"}
+	}
+	d, podName, nodeByPeer, err := clusterTarget(ctx, db, id, req.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	pin, err := kubetopo.Pin(ctx, k8s, d.Namespace, podName, req.CID, kubetopo.PinOptions{
+		Name:           req.Name,
+		ReplicationMin: req.ReplicationMin,
+		ReplicationMax: req.ReplicationMax,
+		Metadata:       req.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pinFromCluster(pin, nodeByPeer), nil
+}
+
+// RemovePin unpins a CID from the cluster of a deployed topology.
+func RemovePin(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id, c, nodeID string) (*Pin, error) {
+	if err := validateCID(c); err != nil {
+		return nil, err
+	}
+	d, podName, nodeByPeer, err := clusterTarget(ctx, db, id, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	pin, err := kubetopo.Unpin(ctx, k8s, d.Namespace, podName, c)
+	if err != nil {
+		return nil, err
+	}
+	return pinFromCluster(pin, nodeByPeer), nil
+}
+
+// GetPin returns the status of one pin on every peer, mapped to canvas nodes.
+func GetPin(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id, c, nodeID string) (*PinStatus, error) {
+	if err := validateCID(c); err != nil {
+		return nil, err
+	}
+	d, podName, nodeByPeer, err := clusterTarget(ctx, db, id, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := kubetopo.GetPinStatus(ctx, k8s, d.Namespace, podName, c)
+	if err != nil {
+		return nil, err
+	}
+	return pinStatusFromCluster(info, nodeByPeer), nil
+}
+
+// ListPins returns the status of every pin of the cluster.
+func ListPins(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id, nodeID string) ([]PinStatus, error) {
+	d, podName, nodeByPeer, err := clusterTarget(ctx, db, id, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := kubetopo.ListPinStatus(ctx, k8s, d.Namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	pins := make([]PinStatus, 0, len(infos))
+	for i := range infos {
+		pins = append(pins, *pinStatusFromCluster(&infos[i], nodeByPeer))
+	}
+	return pins, nil
+}
+
+// clusterTarget resolves the pod whose cluster API serves a request and the
+// cluster peer → node mapping.
+func clusterTarget(ctx context.Context, db *sql.DB, id, nodeID string) (*deployedTopology, string, map[string]string, error) {
+	d, err := loadDeployedTopology(ctx, db, id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	podName, err := d.apiPod(nodeID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	nodeByPeer, _, err := peerNodes(ctx, db, id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return d, podName, nodeByPeer, nil
+}
+
+func validateCID(c string) error {
+	if c == "" {
+		return &ClusterRequestError{Message: "cid is required"}
+	}
+	if _, err := cid.Decode(c); err != nil {
+		return &ClusterRequestError{Message: fmt.Sprintf("invalid cid %q: %v", c, err)}
+	}
+	return nil
+}
+
+func pinFromCluster(p *kubetopo.ClusterPin, nodeByPeer map[string]string) *Pin {
+	return &Pin{
+		CID:            string(p.CID),
+		Name:           p.Name,
+		ReplicationMin: p.ReplicationMin,
+		ReplicationMax: p.ReplicationMax,
+		Allocations:    peersToNodes(p.Allocations, nodeByPeer),
+		Metadata:       p.Metadata,
+	}
+}
+
+func pinStatusFromCluster(info *kubetopo.GlobalPinInfo, nodeByPeer map[string]string) *PinStatus {
+	s := &PinStatus{
+		CID:         string(info.CID),
+		Name:        info.Name,
+		Allocations: peersToNodes(info.Allocations, nodeByPeer),
+		Metadata:    info.Metadata,
+		Peers:       make([]PinPeerStatus, 0, len(info.PeerMap)),
+	}
+	for peerID, p := range info.PeerMap {
+		s.Peers = append(s.Peers, PinPeerStatus{
+			NodeID:    nodeByPeer[peerID],
+			PeerID:    peerID,
+			PeerName:  p.PeerName,
+			Status:    p.Status,
+			Error:     p.Error,
+			Timestamp: p.Timestamp,
+		})
+	}
+	sort.Slice(s.Peers, func(i, j int) bool {
+		if s.Peers[i].NodeID != s.Peers[j].NodeID {
+			return s.Peers[i].NodeID < s.Peers[j].NodeID
+		}
+		return s.Peers[i].PeerID < s.Peers[j].PeerID
+	})
+	return s
+}
+
+// peersToNodes maps cluster peer IDs to canvas nodes; unknown peers are kept as is.
+func peersToNodes(peerIDs []string, nodeByPeer map[string]string) []string {
+	out := make([]string, 0, len(peerIDs))
+	for _, p := range peerIDs {
+		if nodeID, ok := nodeByPeer[p]; ok {
+			p = nodeID
+		}
+		out = append(out, p)
+	}
+	return out
+}
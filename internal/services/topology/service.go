@@ -44,21 +44,41 @@ func GetTopologyByID(ctx context.Context, db *sql.DB, id string) (*Topology, err
 	if err != nil {
 		return nil, err
 	}
+	identities, err := topologymodels.GetNodeIdentitiesByTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	identityByNode := make(map[string]topologymodels.TopologyNodeIdentityModel, len(identities))
+	for _, i := range identities {
+		identityByNode[i.NodeID] = i
+	}
+
+	deployment, err := topologymodels.GetLatestTopologyDeployment(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
 
 	t := &Topology{
 		TopologyID:   m.TopologyID,
 		Name:         m.Name,
 		DeployStatus: m.DeployStatus,
 		K8sNamespace: m.K8sNamespace,
+		Settings:     settingsFromModel(m.Settings),
 		CreatedAt:    m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:    m.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
+	if deployment != nil {
+		t.DeploymentID = &deployment.DeploymentID
+	}
 	for _, n := range nodes {
 		t.Nodes = append(t.Nodes, TopologyNode{
-			NodeID:   n.NodeID,
-			Label:    n.Label,
-			Position: Position{X: n.PosX, Y: n.PosY},
-			Role:     n.Role,
+			NodeID:     n.NodeID,
+			Label:      n.Label,
+			Position:   Position{X: n.PosX, Y: n.PosY},
+			Role:       n.Role,
+			PeerID:     identityByNode[n.NodeID].PeerID,
+			IPFSPeerID: identityByNode[n.NodeID].IPFSPeerID,
+			Overrides:  overridesFromModel(n.Overrides),
 		})
 	}
 	for _, e := range edges {
@@ -71,27 +91,31 @@ func GetTopologyByID(ctx context.Context, db *sql.DB, id string) (*Topology, err
 	return t, nil
 }
 
-func CreateTopology(ctx context.Context, db *sql.DB, req TopologyCreate) (*Topology, error) {
+func CreateTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS string, req TopologyCreate) (*Topology, error) {
+	var settings TopologySettings
+	if req.Settings != nil {
+		settings = *req.Settings
+	}
+	if result := ValidateTopology(&Topology{Nodes: req.Nodes, Edges: req.Edges, Settings: settings}); !result.Valid {
+		return nil, &InvalidTopologyError{Result: result}
+	}
 	id := uuid.NewString()
 	m := &topologymodels.TopologyModel{
 		TopologyID:   id,
 		Name:         req.Name,
 		DeployStatus: "none",
+		Settings:     modelFromSettings(settings),
 	}
-	if err := topologymodels.InsertTopology(ctx, db, m); err != nil {
-		return nil, err
-	}
-	nodeModels := modelsFromNodes(id, req.Nodes)
-	if len(nodeModels) > 0 {
-		if err := topologymodels.ReplaceTopologyNodes(ctx, db, id, nodeModels); err != nil {
+	g := topologymodels.TopologyGraph{Nodes: modelsFromNodes(id, req.Nodes), Edges: modelsFromEdges(id, req.Edges)}
+	// The identity Secret is written first: if it cannot be, nothing is stored.
+	if len(g.Nodes) > 0 {
+		var err error
+		if _, g.Identities, err = persistIdentities(ctx, k8s, identityNS, id, g.Nodes, nil); err != nil {
 			return nil, err
 		}
 	}
-	edgeModels := modelsFromEdges(id, req.Edges)
-	if len(edgeModels) > 0 {
-		if err := topologymodels.ReplaceTopologyEdges(ctx, db, id, edgeModels); err != nil {
-			return nil, err
-		}
+	if err := topologymodels.InsertTopologyGraph(ctx, db, m, g); err != nil {
+		return nil, err
 	}
 	return GetTopologyByID(ctx, db, id)
 }
@@ -114,6 +138,7 @@ func modelsFromNodes(topologyID string, nodes []TopologyNode) []topologymodels.T
 			PosX:       n.Position.X,
 			PosY:       n.Position.Y,
 			Role:       role,
+			Overrides:  modelFromOverrides(n.Overrides),
 		})
 	}
 	return out
@@ -136,109 +161,356 @@ func modelsFromEdges(topologyID string, edges []TopologyEdge) []topologymodels.T
 	return out
 }
 
-func UpdateTopology(ctx context.Context, db *sql.DB, id string, req TopologyUpdate) (*Topology, error) {
+func UpdateTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id string, req TopologyUpdate) (*Topology, error) {
 	m, err := topologymodels.GetTopologyByID(ctx, db, id)
 	if err != nil || m == nil {
 		return nil, err
 	}
+	var current *Topology
+	if req.Nodes != nil || req.Edges != nil || req.Settings != nil {
+		if current, err = GetTopologyByID(ctx, db, id); err != nil {
+			return nil, err
+		}
+		candidate := &Topology{TopologyID: id, Nodes: current.Nodes, Edges: current.Edges, Settings: current.Settings}
+		if req.Nodes != nil {
+			candidate.Nodes = req.Nodes
+		}
+		if req.Edges != nil {
+			candidate.Edges = req.Edges
+		}
+		if req.Settings != nil {
+			candidate.Settings = *req.Settings
+		}
+		if result := ValidateTopology(candidate); !result.Valid {
+			return nil, &InvalidTopologyError{Result: result}
+		}
+	}
 	if req.Name != nil {
 		m.Name = *req.Name
 	}
+	if req.Settings != nil {
+		m.Settings = modelFromSettings(*req.Settings)
+	}
+	var g topologymodels.TopologyGraph
 	if req.Nodes != nil {
-		if err := topologymodels.ReplaceTopologyNodes(ctx, db, id, modelsFromNodes(id, req.Nodes)); err != nil {
+		g.Nodes = modelsFromNodes(id, req.Nodes)
+		// The keys of the current nodes stay in the Secret until the next
+		// update or deploy, in case the Postgres write below fails.
+		currentIDs := make([]string, 0, len(current.Nodes))
+		for _, n := range current.Nodes {
+			currentIDs = append(currentIDs, n.NodeID)
+		}
+		if _, g.Identities, err = persistIdentities(ctx, k8s, identityNS, id, g.Nodes, currentIDs); err != nil {
 			return nil, err
 		}
 	}
 	if req.Edges != nil {
-		if err := topologymodels.ReplaceTopologyEdges(ctx, db, id, modelsFromEdges(id, req.Edges)); err != nil {
-			return nil, err
-		}
+		g.Edges = modelsFromEdges(id, req.Edges)
 	}
-	if err := topologymodels.UpdateTopology(ctx, db, m); err != nil {
+	if err := topologymodels.UpdateTopologyGraph(ctx, db, m, g); err != nil {
 		return nil, err
 	}
 	return GetTopologyByID(ctx, db, id)
 }
 
-func DeleteTopology(ctx context.Context, db *sql.DB, id string) error {
+func ValidateTopologyByID(ctx context.Context, db *sql.DB, id string) (*ValidationResult, error) {
+	t, err := GetTopologyByID(ctx, db, id)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	result := ValidateTopology(t)
+	return &result, nil
+}
+
+func DeleteTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id string) error {
 	_, err := topologymodels.GetTopologyByID(ctx, db, id)
 	if err != nil {
 		return err
 	}
+	stopDeployment(id, errTopologyUndeployed)
+	stopChaos(id, errTopologyUndeployed)
+	if err := kubetopo.DeleteIdentities(ctx, k8s, identityNS, id); err != nil {
+		return err
+	}
 	return topologymodels.DeleteTopology(ctx, db, id)
 }
 
-func DeployTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, id string, namespace string, private bool) (*DeployResult, error) {
+// syncIdentities makes sure every node has a persisted libp2p identity: private keys
+// in the identity Secret, peer IDs in Postgres. Identities of removed nodes are dropped.
+func syncIdentities(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, topologyID string, nodes []topologymodels.TopologyNodeModel) (map[string]kubetopo.NodeIdentity, error) {
+	identities, models, err := persistIdentities(ctx, k8s, identityNS, topologyID, nodes, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := topologymodels.ReplaceTopologyNodeIdentities(ctx, db, topologyID, models); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// persistIdentities saves an identity for every node in the identity Secret and
+// returns the identities with their public part for Postgres. Identities of
+// other nodes are dropped from the Secret, apart from those of keepIDs.
+func persistIdentities(ctx context.Context, k8s *kubernetes.Clientset, identityNS, topologyID string, nodes []topologymodels.TopologyNodeModel, keepIDs []string) (map[string]kubetopo.NodeIdentity, []topologymodels.TopologyNodeIdentityModel, error) {
+	nodeIDs := make([]string, 0, len(nodes)+len(keepIDs))
+	for _, n := range nodes {
+		nodeIDs = append(nodeIDs, n.NodeID)
+	}
+	identities, err := kubetopo.EnsureIdentities(ctx, k8s, identityNS, topologyID, append(nodeIDs, keepIDs...))
+	if err != nil {
+		return nil, nil, err
+	}
+	models := make([]topologymodels.TopologyNodeIdentityModel, 0, len(nodes))
+	for _, nodeID := range nodeIDs {
+		i := identities[nodeID]
+		models = append(models, topologymodels.TopologyNodeIdentityModel{
+			TopologyID: topologyID,
+			NodeID:     nodeID,
+			PeerID:     i.ClusterKey.PeerID,
+			IPFSPeerID: i.IPFSKey.PeerID,
+		})
+	}
+	return identities, models, nil
+}
+
+// DeployTopology applies the topology objects and starts a deployment worker that
+// follows the rollout; the returned deploymentId tracks its progress.
+func DeployTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id string, opts DeployOptions) (*DeployResult, error) {
+	cfg, err := prepareDeploy(ctx, db, k8s, identityNS, id, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	deployment, changes, err := startDeployment(ctx, db, k8s, *cfg, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &DeployResult{
+		TopologyID:   id,
+		DeploymentID: deployment.DeploymentID,
+		Status:       "deploying",
+		Message:      "Deployment started",
+		Networks:     len(cfg.Networks),
+		Changes:      changes,
+	}, nil
+}
+
+// PlanDeployment renders the Kubernetes objects a deploy would create without
+// creating them. With serverDryRun they are submitted with DryRun: All.
+func PlanDeployment(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id string, opts DeployOptions, serverDryRun bool) (*DeployPlan, error) {
+	cfg, err := prepareDeploy(ctx, db, k8s, identityNS, id, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	objects, assignments, err := kubetopo.Plan(ctx, k8s, *cfg, serverDryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &DeployPlan{
+		TopologyID:   id,
+		Namespace:    cfg.Namespace,
+		ServerDryRun: serverDryRun,
+		Networks:     len(cfg.Networks),
+		Pods:         make([]PlannedPod, 0, len(assignments)),
+		Objects:      objects,
+	}
+	for _, a := range assignments {
+		plan.Pods = append(plan.Pods, PlannedPod{NodeID: a.NodeID, PodName: a.PodName, Workload: a.Workload, Ordinal: a.Ordinal})
+	}
+	for _, o := range objects {
+		if o.Error != "" {
+			plan.Errors++
+		}
+	}
+	return plan, nil
+}
+
+// ExportManifests renders the objects a deploy would create as a YAML stream, a
+// Helm chart or a Kustomize base, with placeholders instead of secret values.
+func ExportManifests(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id, format string, opts DeployOptions) (*kubetopo.Manifests, error) {
+	cfg, err := prepareDeploy(ctx, db, k8s, identityNS, id, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return kubetopo.RenderManifests(*cfg, format)
+}
+
+// prepareDeploy validates the topology, resolves its networks and identities and
+// builds the kube deploy config shared by deploys, plans and exports. Plans and
+// exports pass persist false and change nothing, see deploySecrets.
+func prepareDeploy(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id string, opts DeployOptions, persist bool) (*kubetopo.DeployConfig, error) {
 	t, err := GetTopologyByID(ctx, db, id)
-	if err != nil || t == nil {
-		return nil, fmt.Errorf("topology not found: %s", id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTopologyNotFound, id)
 	}
 	if len(t.Nodes) == 0 {
-		return nil, fmt.Errorf("topology has no nodes")
+		return nil, &DeployRequestError{Message: "topology has no nodes"}
+	}
+	if result := ValidateTopology(t); !result.Valid {
+		return nil, &InvalidTopologyError{Result: result}
 	}
 
-	bootstrapID := resolveBootstrapNode(t)
-	if bootstrapID == "" {
-		return nil, fmt.Errorf("topology must have exactly one bootstrap node (node that others connect to)")
+	networks, bootstrapIDs, err := planNetworks(t, opts.Disconnected)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := topologymodels.UpdateTopologyDeployStatus(ctx, db, id, "deploying", &namespace); err != nil {
+	identities, clusterSecrets, swarmKeys, err := deploySecrets(ctx, db, k8s, identityNS, t, max(len(networks), 1), persist)
+	if err != nil {
 		return nil, err
 	}
 
-	cfg := kubetopo.DeployConfig{
-		TopologyID:  id,
-		Name:        t.Name,
-		Namespace:   namespace,
-		BootstrapID: bootstrapID,
-		Private:     private,
+	pods, err := topologymodels.GetNodePodsByTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Request overrides win over the topology settings, which win over the server
+	// defaults. A private deploy ignores the server's service type so that it stays
+	// ClusterIP unless the topology asks otherwise.
+	defaults := opts.Defaults
+	if opts.Private {
+		defaults.ServiceType = ""
+	}
+	cfg := &kubetopo.DeployConfig{
+		TopologyID:   id,
+		Name:         t.Name,
+		Namespace:    opts.Namespace,
+		BootstrapIDs: bootstrapIDs,
+		Networks:     networks,
+		Private:      opts.Private,
+		Workload:     opts.Workload.Or(t.Settings.workload()).Or(defaults),
+		Kubo:         t.Settings.kubo(),
+		Cluster:      t.Settings.cluster(),
+
+		NetworkPolicies: opts.NetworkPolicies,
+		APISources:      opts.APISources,
+		PrivateNetwork:  t.Settings.PrivateNetwork,
+		SwarmKeys:       swarmKeys,
+		ClusterSecrets:  clusterSecrets,
+	}
+	for _, p := range pods {
+		cfg.Assignments = append(cfg.Assignments, kubetopo.PodAssignment{NodeID: p.NodeID, PodName: p.PodName, Workload: p.Workload, Ordinal: p.Ordinal})
 	}
 	for _, n := range t.Nodes {
+		var identity *kubetopo.NodeIdentity
+		if i, ok := identities[n.NodeID]; ok {
+			identity = &i
+		}
 		cfg.Nodes = append(cfg.Nodes, kubetopo.NodeInfo{
-			NodeID:   n.NodeID,
-			Label:    n.Label,
+			NodeID: n.NodeID,
+			Label:  n.Label,
 			Position: struct {
 				X float64 `json:"x"`
 				Y float64 `json:"y"`
 			}{X: n.Position.X, Y: n.Position.Y},
-			Role: n.Role,
+			Role:      n.Role,
+			Identity:  identity,
+			Overrides: n.Overrides.overrides(),
+			Kubo:      n.Overrides.kubo(),
 		})
 	}
 	for _, e := range t.Edges {
 		cfg.Edges = append(cfg.Edges, kubetopo.EdgeInfo{SourceNodeID: e.SourceNodeID, TargetNodeID: e.TargetNodeID})
 	}
-	if err := kubetopo.Deploy(ctx, k8s, cfg); err != nil {
-		_ = topologymodels.UpdateTopologyDeployStatus(ctx, db, id, "error", &namespace)
-		return nil, err
+	return cfg, nil
+}
+
+// deploySecrets returns the node identities and the cluster secrets and swarm keys
+// of n networks. With persist the missing ones are generated and saved; without
+// it the identity Secret and Postgres are only read and the missing ones are left
+// out for BuildObjects to generate.
+func deploySecrets(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS string, t *Topology, n int, persist bool) (map[string]kubetopo.NodeIdentity, []string, []string, error) {
+	if !persist {
+		nodeIDs := make([]string, 0, len(t.Nodes))
+		for _, node := range t.Nodes {
+			nodeIDs = append(nodeIDs, node.NodeID)
+		}
+		identities, err := kubetopo.LoadIdentities(ctx, k8s, identityNS, t.TopologyID, nodeIDs)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		clusterSecrets, err := kubetopo.LoadClusterSecrets(ctx, k8s, identityNS, t.TopologyID, n)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		var swarmKeys []string
+		if t.Settings.PrivateNetwork {
+			if swarmKeys, err = kubetopo.LoadSwarmKeys(ctx, k8s, identityNS, t.TopologyID, n); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		return identities, clusterSecrets, swarmKeys, nil
 	}
-	_ = topologymodels.UpdateTopologyDeployStatus(ctx, db, id, "running", &namespace)
 
-	return &DeployResult{TopologyID: id, Status: "deploying", Message: "Deployment started"}, nil
+	nodeModels := make([]topologymodels.TopologyNodeModel, 0, len(t.Nodes))
+	for _, node := range t.Nodes {
+		nodeModels = append(nodeModels, topologymodels.TopologyNodeModel{NodeID: node.NodeID})
+	}
+	identities, err := syncIdentities(ctx, db, k8s, identityNS, t.TopologyID, nodeModels)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	clusterSecrets, err := kubetopo.EnsureClusterSecrets(ctx, k8s, identityNS, t.TopologyID, n)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var swarmKeys []string
+	if t.Settings.PrivateNetwork {
+		if swarmKeys, err = kubetopo.EnsureSwarmKeys(ctx, k8s, identityNS, t.TopologyID, n); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return identities, clusterSecrets, swarmKeys, nil
 }
 
-func resolveBootstrapNode(t *Topology) string {
-	targets := make(map[string]bool)
-	for _, e := range t.Edges {
-		targets[e.TargetNodeID] = true
-	}
-	sources := make(map[string]bool)
-	for _, e := range t.Edges {
-		sources[e.SourceNodeID] = true
+// planNetworks checks every connected component for a bootstrap node and decides how
+// disconnected components are handled: rejected (default) or deployed as independent
+// ipfs-cluster networks. It returns the node IDs of each network and all bootstrap nodes.
+func planNetworks(t *Topology, disconnected string) ([][]string, []string, error) {
+	switch disconnected {
+	case "", DisconnectedReject, DisconnectedIndependent:
+	default:
+		return nil, nil, &DeployRequestError{Message: fmt.Sprintf("unknown disconnected mode %q (expected %s or %s)",
+			disconnected, DisconnectedReject, DisconnectedIndependent)}
 	}
+
+	labels := make(map[string]string, len(t.Nodes))
 	for _, n := range t.Nodes {
-		if n.Role == "bootstrap" {
-			return n.NodeID
+		labels[n.NodeID] = n.NodeID
+		if n.Label != "" {
+			labels[n.NodeID] = n.Label
 		}
 	}
-	for _, n := range t.Nodes {
-		if targets[n.NodeID] && !sources[n.NodeID] {
-			return n.NodeID
+	named := func(ids []string) []string {
+		out := make([]string, 0, len(ids))
+		for _, id := range ids {
+			out = append(out, labels[id])
+		}
+		return out
+	}
+
+	comps := components(t)
+	if len(comps) > 1 && disconnected != DisconnectedIndependent {
+		err := &DisconnectedTopologyError{}
+		for _, c := range comps {
+			err.Components = append(err.Components, named(c))
 		}
+		return nil, nil, err
 	}
-	if len(t.Nodes) == 1 {
-		return t.Nodes[0].NodeID
+
+	var bootstrapIDs []string
+	for _, c := range comps {
+		roots := resolveBootstrapNodes(t, c)
+		if len(roots) == 0 {
+			return nil, nil, &MissingBootstrapError{Component: named(c)}
+		}
+		bootstrapIDs = append(bootstrapIDs, roots...)
 	}
-	return ""
+	return comps, bootstrapIDs, nil
 }
 
 func UndeployTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, id string) error {
@@ -250,9 +522,16 @@ func UndeployTopology(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset
 	if t.K8sNamespace != nil {
 		ns = *t.K8sNamespace
 	}
+	stopDeployment(id, errTopologyUndeployed)
+	// Reverting before the pods go matters for fill-disk: its file would
+	// outlive them on the volume.
+	stopChaos(id, errTopologyUndeployed)
 	if err := kubetopo.Undeploy(ctx, k8s, id, ns); err != nil {
 		return err
 	}
+	if err := topologymodels.ReplaceTopologyNodePods(ctx, db, id, nil); err != nil {
+		return err
+	}
 	return topologymodels.UpdateTopologyDeployStatus(ctx, db, id, "none", nil)
 }
 
@@ -265,13 +544,17 @@ func GetDeployStatus(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset,
 	if t.K8sNamespace != nil {
 		ns = *t.K8sNamespace
 	}
+	nodeByPod, _, err := nodePodMaps(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
 	rawPods, err := kubetopo.GetPodsStatus(ctx, k8s, id, ns)
 	if err != nil {
 		return &DeployStatus{TopologyID: id, Status: t.DeployStatus, Message: err.Error()}, nil
 	}
 	pods := make([]PodStatus, 0, len(rawPods))
 	for _, p := range rawPods {
-		pods = append(pods, PodStatus{NodeID: p.NodeID, PodName: p.PodName, Phase: p.Phase, Ready: p.Ready})
+		pods = append(pods, PodStatus{NodeID: nodeByPod[p.PodName], PodName: p.PodName, Phase: p.Phase, Ready: p.Ready})
 	}
 	return &DeployStatus{
 		TopologyID: id,
@@ -280,14 +563,63 @@ func GetDeployStatus(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset,
 	}, nil
 }
 
-func GetPodLogs(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, topologyID, podName, container string) (string, error) {
+// GetPodLogs opens the log stream of a topology pod together with the canvas node it is assigned to.
+func GetPodLogs(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, topologyID, podName string, opts kubetopo.LogOptions) (*PodLogs, error) {
 	t, err := GetTopologyByID(ctx, db, topologyID)
 	if err != nil || t == nil {
-		return "", fmt.Errorf("topology not found: %s", topologyID)
+		return nil, fmt.Errorf("topology not found: %s", topologyID)
 	}
+	nodeByPod, _, err := nodePodMaps(ctx, db, topologyID)
+	if err != nil {
+		return nil, err
+	}
+	nodeID, ok := nodeByPod[podName]
+	if !ok {
+		return nil, fmt.Errorf("pod %s is not part of topology %s", podName, topologyID)
+	}
+	return podLogs(ctx, k8s, t, nodeID, podName, opts)
+}
+
+// GetNodeLogs opens the log stream of the pod a canvas node was deployed as.
+func GetNodeLogs(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, topologyID, nodeID string, opts kubetopo.LogOptions) (*PodLogs, error) {
+	t, err := GetTopologyByID(ctx, db, topologyID)
+	if err != nil || t == nil {
+		return nil, fmt.Errorf("topology not found: %s", topologyID)
+	}
+	_, podByNode, err := nodePodMaps(ctx, db, topologyID)
+	if err != nil {
+		return nil, err
+	}
+	podName, ok := podByNode[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("node %s is not deployed in topology %s", nodeID, topologyID)
+	}
+	return podLogs(ctx, k8s, t, nodeID, podName, opts)
+}
+
+func podLogs(ctx context.Context, k8s *kubernetes.Clientset, t *Topology, nodeID, podName string, opts kubetopo.LogOptions) (*PodLogs, error) {
 	ns := "default"
 	if t.K8sNamespace != nil {
 		ns = *t.K8sNamespace
 	}
-	return kubetopo.GetPodLogs(ctx, k8s, ns, podName, container)
+	stream, err := kubetopo.GetPodLogs(ctx, k8s, ns, podName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &PodLogs{NodeID: nodeID, PodName: podName, Stream: stream}, nil
+}
+
+// nodePodMaps loads the node → pod assignment of the latest deploy in both directions.
+func nodePodMaps(ctx context.Context, db *sql.DB, topologyID string) (map[string]string, map[string]string, error) {
+	rows, err := topologymodels.GetNodePodsByTopology(ctx, db, topologyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodeByPod := make(map[string]string, len(rows))
+	podByNode := make(map[string]string, len(rows))
+	for _, r := range rows {
+		nodeByPod[r.PodName] = r.NodeID
+		podByNode[r.NodeID] = r.PodName
+	}
+	return nodeByPod, podByNode, nil
 }
@@ -0,0 +1,254 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetTopologySettings returns the stored settings of a topology and the values
+// a public deploy would use with the given server defaults.
+func GetTopologySettings(ctx context.Context, db *sql.DB, id string, defaults kubetopo.Workload) (*SettingsView, error) {
+	m, err := topologymodels.GetTopologyByID(ctx, db, id)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	settings := settingsFromModel(m.Settings)
+	effective := settingsFromWorkload(settings.workload().Or(defaults).Or(kubetopo.DefaultWorkload()))
+	kubo := kubetopo.DefaultKuboConfig().Merge(settings.kubo())
+	effective.IPFSProfiles, effective.IPFSConfig = kubo.Profiles, kubo.Config
+	effective.Cluster = clusterSettings(settings.cluster().Or(kubetopo.DefaultClusterConfig()))
+	effective.PrivateNetwork = settings.PrivateNetwork
+	return &SettingsView{
+		TopologyID: id,
+		Settings:   settings,
+		Effective:  effective,
+	}, nil
+}
+
+// UpdateTopologySettings replaces the settings of a topology.
+func UpdateTopologySettings(ctx context.Context, db *sql.DB, k8s *kubernetes.Clientset, identityNS, id string, settings TopologySettings, defaults kubetopo.Workload) (*SettingsView, error) {
+	t, err := UpdateTopology(ctx, db, k8s, identityNS, id, TopologyUpdate{Settings: &settings})
+	if err != nil || t == nil {
+		return nil, err
+	}
+	return GetTopologySettings(ctx, db, id, defaults)
+}
+
+// workload maps the settings onto the kube workload parameters.
+func (s TopologySettings) workload() kubetopo.Workload {
+	return kubetopo.Workload{
+		IPFSImage:          s.IPFSImage,
+		ClusterImage:       s.ClusterImage,
+		StorageClass:       s.StorageClass,
+		IPFSStorageSize:    s.IPFSStorageSize,
+		ClusterStorageSize: s.ClusterStorageSize,
+		ServiceType:        corev1.ServiceType(s.ServiceType),
+		IPFSResources:      s.IPFSResources.resources(),
+		ClusterResources:   s.ClusterResources.resources(),
+	}
+}
+
+// kubo maps the settings onto the kubo profiles and config values.
+func (s TopologySettings) kubo() kubetopo.KuboConfig {
+	return kubetopo.KuboConfig{Profiles: s.IPFSProfiles, Config: s.IPFSConfig}
+}
+
+// cluster maps the settings onto the ipfs-cluster config.
+func (s TopologySettings) cluster() kubetopo.ClusterConfig {
+	c := s.Cluster
+	if c == nil {
+		return kubetopo.ClusterConfig{}
+	}
+	return kubetopo.ClusterConfig{
+		Consensus:            c.Consensus,
+		ReplicationFactorMin: c.ReplicationFactorMin,
+		ReplicationFactorMax: c.ReplicationFactorMax,
+		TrustedPeers:         c.TrustedPeers,
+		MonitorPingInterval:  c.MonitorPingInterval,
+		MonitorCheckInterval: c.MonitorCheckInterval,
+		AllocateBy:           c.AllocateBy,
+	}
+}
+
+func clusterSettings(c kubetopo.ClusterConfig) *ClusterSettings {
+	return &ClusterSettings{
+		Consensus:            c.Consensus,
+		ReplicationFactorMin: c.ReplicationFactorMin,
+		ReplicationFactorMax: c.ReplicationFactorMax,
+		TrustedPeers:         c.TrustedPeers,
+		MonitorPingInterval:  c.MonitorPingInterval,
+		MonitorCheckInterval: c.MonitorCheckInterval,
+		AllocateBy:           c.AllocateBy,
+	}
+}
+
+func (r *ContainerResources) resources() kubetopo.Resources {
+	if r == nil {
+		return kubetopo.Resources{}
+	}
+	return kubetopo.Resources{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+}
+
+func settingsFromWorkload(w kubetopo.Workload) TopologySettings {
+	return TopologySettings{
+		IPFSImage:          w.IPFSImage,
+		ClusterImage:       w.ClusterImage,
+		StorageClass:       w.StorageClass,
+		IPFSStorageSize:    w.IPFSStorageSize,
+		ClusterStorageSize: w.ClusterStorageSize,
+		ServiceType:        string(w.ServiceType),
+		IPFSResources:      containerResources(w.IPFSResources),
+		ClusterResources:   containerResources(w.ClusterResources),
+	}
+}
+
+func containerResources(r kubetopo.Resources) *ContainerResources {
+	if r == (kubetopo.Resources{}) {
+		return nil
+	}
+	return &ContainerResources{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+}
+
+func settingsFromModel(m topologymodels.TopologySettingsModel) TopologySettings {
+	s := TopologySettings{
+		IPFSImage:          m.IPFSImage,
+		ClusterImage:       m.ClusterImage,
+		StorageClass:       m.StorageClass,
+		IPFSStorageSize:    m.IPFSStorageSize,
+		ClusterStorageSize: m.ClusterStorageSize,
+		ServiceType:        m.ServiceType,
+		IPFSProfiles:       m.IPFSProfiles,
+		IPFSConfig:         m.IPFSConfig,
+		PrivateNetwork:     m.PrivateNetwork,
+	}
+	if c := m.Cluster; c != nil {
+		s.Cluster = &ClusterSettings{
+			Consensus:            c.Consensus,
+			ReplicationFactorMin: c.ReplicationFactorMin,
+			ReplicationFactorMax: c.ReplicationFactorMax,
+			TrustedPeers:         c.TrustedPeers,
+			MonitorPingInterval:  c.MonitorPingInterval,
+			MonitorCheckInterval: c.MonitorCheckInterval,
+			AllocateBy:           c.AllocateBy,
+		}
+	}
+	if r := m.IPFSResources; r != nil {
+		s.IPFSResources = &ContainerResources{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	if r := m.ClusterResources; r != nil {
+		s.ClusterResources = &ContainerResources{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	return s
+}
+
+func modelFromSettings(s TopologySettings) topologymodels.TopologySettingsModel {
+	m := topologymodels.TopologySettingsModel{
+		IPFSImage:          s.IPFSImage,
+		ClusterImage:       s.ClusterImage,
+		StorageClass:       s.StorageClass,
+		IPFSStorageSize:    s.IPFSStorageSize,
+		ClusterStorageSize: s.ClusterStorageSize,
+		ServiceType:        s.ServiceType,
+		IPFSProfiles:       s.IPFSProfiles,
+		IPFSConfig:         s.IPFSConfig,
+		PrivateNetwork:     s.PrivateNetwork,
+	}
+	if c := s.Cluster; c != nil {
+		m.Cluster = &topologymodels.ClusterSettingsModel{
+			Consensus:            c.Consensus,
+			ReplicationFactorMin: c.ReplicationFactorMin,
+			ReplicationFactorMax: c.ReplicationFactorMax,
+			TrustedPeers:         c.TrustedPeers,
+			MonitorPingInterval:  c.MonitorPingInterval,
+			MonitorCheckInterval: c.MonitorCheckInterval,
+			AllocateBy:           c.AllocateBy,
+		}
+	}
+	if r := s.IPFSResources; r != nil {
+		m.IPFSResources = &topologymodels.ContainerResourcesModel{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	if r := s.ClusterResources; r != nil {
+		m.ClusterResources = &topologymodels.ContainerResourcesModel{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	return m
+}
+
+// overrides maps node overrides onto the kube ones; nil means none.
+func (o *NodeOverrides) overrides() kubetopo.NodeOverrides {
+	if o == nil {
+		return kubetopo.NodeOverrides{}
+	}
+	return kubetopo.NodeOverrides{
+		IPFSImageTag:       o.IPFSImageTag,
+		ClusterImageTag:    o.ClusterImageTag,
+		IPFSStorageSize:    o.IPFSStorageSize,
+		ClusterStorageSize: o.ClusterStorageSize,
+		IPFSResources:      o.IPFSResources.resources(),
+		ClusterResources:   o.ClusterResources.resources(),
+		Env:                o.Env,
+		Labels:             o.Labels,
+		Annotations:        o.Annotations,
+	}
+}
+
+func overridesFromModel(m topologymodels.NodeOverridesModel) *NodeOverrides {
+	o := &NodeOverrides{
+		IPFSImageTag:       m.IPFSImageTag,
+		ClusterImageTag:    m.ClusterImageTag,
+		IPFSStorageSize:    m.IPFSStorageSize,
+		ClusterStorageSize: m.ClusterStorageSize,
+		Env:                m.Env,
+		Labels:             m.Labels,
+		Annotations:        m.Annotations,
+		IPFSProfiles:       m.IPFSProfiles,
+		IPFSConfig:         m.IPFSConfig,
+	}
+	if r := m.IPFSResources; r != nil {
+		o.IPFSResources = &ContainerResources{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	if r := m.ClusterResources; r != nil {
+		o.ClusterResources = &ContainerResources{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	if o.overrides().IsZero() && len(o.IPFSProfiles) == 0 && len(o.IPFSConfig) == 0 {
+		return nil
+	}
+	return o
+}
+
+// kubo maps the node's kubo profiles and config values; nil means none.
+func (o *NodeOverrides) kubo() kubetopo.KuboConfig {
+	if o == nil {
+		return kubetopo.KuboConfig{}
+	}
+	return kubetopo.KuboConfig{Profiles: o.IPFSProfiles, Config: o.IPFSConfig}
+}
+
+func modelFromOverrides(o *NodeOverrides) topologymodels.NodeOverridesModel {
+	if o == nil {
+		return topologymodels.NodeOverridesModel{}
+	}
+	m := topologymodels.NodeOverridesModel{
+		IPFSImageTag:       o.IPFSImageTag,
+		ClusterImageTag:    o.ClusterImageTag,
+		IPFSStorageSize:    o.IPFSStorageSize,
+		ClusterStorageSize: o.ClusterStorageSize,
+		Env:                o.Env,
+		Labels:             o.Labels,
+		Annotations:        o.Annotations,
+		IPFSProfiles:       o.IPFSProfiles,
+		IPFSConfig:         o.IPFSConfig,
+	}
+	if r := o.IPFSResources; r != nil {
+		m.IPFSResources = &topologymodels.ContainerResourcesModel{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	if r := o.ClusterResources; r != nil {
+		m.ClusterResources = &topologymodels.ContainerResourcesModel{CPURequest: r.CPURequest, CPULimit: r.CPULimit, MemoryRequest: r.MemoryRequest, MemoryLimit: r.MemoryLimit}
+	}
+	return m
+}
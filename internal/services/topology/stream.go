@@ -0,0 +1,64 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+)
+
+// StreamStatus subscribes to pod changes of a deployed topology and maps every pod
+// to its canvas node. The channel is closed when ctx is done.
+func StreamStatus(ctx context.Context, db *sql.DB, watchers *kubetopo.PodWatchers, id string) (<-chan PodStatusEvent, error) {
+	t, err := GetTopologyByID(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrTopologyNotFound, id)
+	}
+	ns := "default"
+	if t.K8sNamespace != nil {
+		ns = *t.K8sNamespace
+	}
+	nodeByPod, _, err := nodePodMaps(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	events, err := watchers.Subscribe(ctx, id, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan PodStatusEvent)
+	go func() {
+		defer close(out)
+		for e := range events {
+			nodeID, ok := nodeByPod[e.Pod.PodName]
+			if !ok {
+				// The pod may come from a deploy that finished after the stream started.
+				if fresh, _, err := nodePodMaps(ctx, db, id); err == nil {
+					nodeByPod = fresh
+					nodeID = nodeByPod[e.Pod.PodName]
+				} else if ctx.Err() == nil {
+					slog.Error("StreamStatus", "error", err)
+				}
+			}
+			select {
+			case out <- PodStatusEvent{
+				Type:     e.Type,
+				NodeID:   nodeID,
+				PodName:  e.Pod.PodName,
+				Phase:    e.Pod.Phase,
+				Ready:    e.Pod.Ready,
+				Restarts: e.Pod.Restarts,
+				Reason:   e.Pod.Reason,
+			}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	topologymodels "ipfs-visualizer/internal/db/psql/models/topologyModels"
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	EdgeConnected = "connected"
+	EdgeMissing   = "missing"
+	// EdgeUnknown means neither end answered: both are undeployed or their kubo
+	// API failed. One answering end is enough to tell connected from missing,
+	// as a libp2p connection is listed on both of its ends.
+	EdgeUnknown = "unknown"
+)
+
+// GetEdgeConformance compares the libp2p connections of the deployed kubo nodes
+// with the edges of the topology. Connections are undirected: an edge counts as
+// connected if either end reports the other among its swarm peers.
+func GetEdgeConformance(ctx context.Context, db *sql.DB, k8s kubernetes.Interface, id string) (*EdgeConformance, error) {
+	d, err := loadDeployedTopology(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	_, nodeByPeer, err := peerNodes(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]NodeSwarm, len(d.Pods))
+	connected := make([][]string, len(d.Pods))
+	eachPod(ctx, d.Pods, func(ctx context.Context, i int, p topologymodels.TopologyNodePodModel) {
+		nodes[i] = NodeSwarm{NodeID: p.NodeID, PodName: p.PodName}
+		peers, err := kubetopo.GetSwarmPeers(ctx, k8s, d.Namespace, p.PodName)
+		if err != nil {
+			nodes[i].Error = err.Error()
+			return
+		}
+		nodes[i].Reachable = true
+		nodes[i].Peers = len(peers)
+		for _, sp := range peers {
+			if nodeID, ok := nodeByPeer[sp.Peer]; ok && nodeID != p.NodeID {
+				connected[i] = append(connected[i], nodeID)
+			} else if !ok {
+				nodes[i].ExternalPeers++
+			}
+		}
+	})
+
+	reachable := map[string]bool{}
+	observed := map[[2]string]bool{}
+	for i, n := range nodes {
+		reachable[n.NodeID] = n.Reachable
+		for _, other := range connected[i] {
+			observed[edgeKey(n.NodeID, other)] = true
+		}
+	}
+
+	result := &EdgeConformance{TopologyID: id, Conformant: true, Nodes: nodes, Edges: []EdgeStatus{}, Extra: []ObservedEdge{}}
+	designed := map[[2]string]bool{}
+	for _, e := range d.Edges {
+		key := edgeKey(e.SourceNodeID, e.TargetNodeID)
+		designed[key] = true
+		status := EdgeMissing
+		switch {
+		case observed[key]:
+			status = EdgeConnected
+		case !reachable[e.SourceNodeID] && !reachable[e.TargetNodeID]:
+			status = EdgeUnknown
+		}
+		if status != EdgeConnected {
+			result.Conformant = false
+		}
+		result.Edges = append(result.Edges, EdgeStatus{EdgeID: e.EdgeID, SourceNodeID: e.SourceNodeID, TargetNodeID: e.TargetNodeID, Status: status})
+	}
+	for key := range observed {
+		if !designed[key] {
+			result.Extra = append(result.Extra, ObservedEdge{SourceNodeID: key[0], TargetNodeID: key[1]})
+		}
+	}
+	sort.Slice(result.Extra, func(i, j int) bool {
+		if result.Extra[i].SourceNodeID != result.Extra[j].SourceNodeID {
+			return result.Extra[i].SourceNodeID < result.Extra[j].SourceNodeID
+		}
+		return result.Extra[i].TargetNodeID < result.Extra[j].TargetNodeID
+	})
+	return result, nil
+}
+
+// edgeKey identifies an undirected connection between two nodes.
+func edgeKey(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
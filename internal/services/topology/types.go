@@ -1,5 +1,14 @@
 package topology
 
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	kubetopo "ipfs-visualizer/internal/kube/topology"
+)
+
 type Topology struct {
 	TopologyID   string        `json:"topologyId"`
 	Name         string        `json:"name"`
@@ -7,6 +16,8 @@ type Topology struct {
 	Edges        []TopologyEdge `json:"edges"`
 	DeployStatus string        `json:"deployStatus"`
 	K8sNamespace *string       `json:"k8sNamespace,omitempty"`
+	DeploymentID *string       `json:"deploymentId,omitempty"` // latest deployment, see /v1/deployments/{id}
+	Settings     TopologySettings `json:"settings"`
 	CreatedAt    string        `json:"createdAt"`
 	UpdatedAt    string        `json:"updatedAt"`
 }
@@ -25,6 +36,28 @@ type TopologyNode struct {
 	Label    string    `json:"label"`
 	Position Position  `json:"position"`
 	Role     string    `json:"role"` // bootstrap | worker
+	// PeerID and IPFSPeerID are the persisted ipfs-cluster and kubo peer IDs; read-only.
+	PeerID     string `json:"peerId,omitempty"`
+	IPFSPeerID string `json:"ipfsPeerId,omitempty"`
+	// Overrides customise this node's pod; nodes with equal overrides share a StatefulSet.
+	Overrides *NodeOverrides `json:"overrides,omitempty"`
+}
+
+// NodeOverrides replace parts of the topology settings for one node.
+type NodeOverrides struct {
+	IPFSImageTag       string              `json:"ipfsImageTag,omitempty"`
+	ClusterImageTag    string              `json:"clusterImageTag,omitempty"`
+	IPFSStorageSize    string              `json:"ipfsStorageSize,omitempty"`
+	ClusterStorageSize string              `json:"clusterStorageSize,omitempty"`
+	IPFSResources      *ContainerResources `json:"ipfsResources,omitempty"`
+	ClusterResources   *ContainerResources `json:"clusterResources,omitempty"`
+	Env                map[string]string   `json:"env,omitempty"` // added to the kubo and ipfs-cluster containers
+	Labels             map[string]string   `json:"labels,omitempty"`
+	Annotations        map[string]string   `json:"annotations,omitempty"`
+	// IPFSProfiles replace the topology's init profiles; IPFSConfig values are
+	// set on top of the topology's. Neither moves the node to its own StatefulSet.
+	IPFSProfiles []string                   `json:"ipfsProfiles,omitempty"`
+	IPFSConfig   map[string]json.RawMessage `json:"ipfsConfig,omitempty"`
 }
 
 type Position struct {
@@ -38,22 +71,150 @@ type TopologyEdge struct {
 	TargetNodeID string `json:"targetNodeId"` // bootstrap
 }
 
+// TopologySettings are the deploy settings of a topology. Empty fields fall back
+// to the server configuration (CLUSTER_* variables), then to built-in defaults.
+type TopologySettings struct {
+	IPFSImage          string              `json:"ipfsImage,omitempty"`
+	ClusterImage       string              `json:"clusterImage,omitempty"`
+	StorageClass       string              `json:"storageClass,omitempty"`
+	IPFSStorageSize    string              `json:"ipfsStorageSize,omitempty"`
+	ClusterStorageSize string              `json:"clusterStorageSize,omitempty"`
+	ServiceType        string              `json:"serviceType,omitempty"` // ClusterIP | NodePort | LoadBalancer
+	IPFSResources      *ContainerResources `json:"ipfsResources,omitempty"`
+	ClusterResources   *ContainerResources `json:"clusterResources,omitempty"`
+	// IPFSProfiles are the kubo init profiles (e.g. flatfs, pebbleds, lowpower);
+	// they take effect when a node's repo is created.
+	IPFSProfiles []string `json:"ipfsProfiles,omitempty"`
+	// IPFSConfig maps dotted kubo config keys (Routing.Type, Reprovider.Interval,
+	// Experimental.FilestoreEnabled, ...) to JSON values set on every pod start.
+	IPFSConfig map[string]json.RawMessage `json:"ipfsConfig,omitempty"`
+	Cluster    *ClusterSettings           `json:"cluster,omitempty"`
+	// PrivateNetwork isolates the kubo nodes of each network behind a generated
+	// swarm key: no public bootstrap peers, LIBP2P_FORCE_PNET set.
+	PrivateNetwork bool `json:"privateNetwork,omitempty"`
+}
+
+// ClusterSettings configure the ipfs-cluster peers through the generated
+// service.json. Zero fields fall back to the ipfs-cluster defaults.
+type ClusterSettings struct {
+	Consensus            string   `json:"consensus,omitempty"` // crdt | raft
+	ReplicationFactorMin int      `json:"replicationFactorMin,omitempty"`
+	ReplicationFactorMax int      `json:"replicationFactorMax,omitempty"`
+	TrustedPeers         []string `json:"trustedPeers,omitempty"` // node IDs or "*"
+	MonitorPingInterval  string   `json:"monitorPingInterval,omitempty"`
+	MonitorCheckInterval string   `json:"monitorCheckInterval,omitempty"`
+	AllocateBy           []string `json:"allocateBy,omitempty"`
+}
+
+// ContainerResources are CPU and memory requests and limits as Kubernetes quantities.
+type ContainerResources struct {
+	CPURequest    string `json:"cpuRequest,omitempty"`
+	CPULimit      string `json:"cpuLimit,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+}
+
+// SettingsView is a topology's stored settings next to the values a deploy
+// would use for them.
+type SettingsView struct {
+	TopologyID string           `json:"topologyId"`
+	Settings   TopologySettings `json:"settings"`
+	Effective  TopologySettings `json:"effective"`
+}
+
 type TopologyCreate struct {
-	Name  string         `json:"name"`
-	Nodes []TopologyNode `json:"nodes,omitempty"`
-	Edges []TopologyEdge `json:"edges,omitempty"`
+	Name     string            `json:"name"`
+	Nodes    []TopologyNode    `json:"nodes,omitempty"`
+	Edges    []TopologyEdge    `json:"edges,omitempty"`
+	Settings *TopologySettings `json:"settings,omitempty"`
 }
 
 type TopologyUpdate struct {
-	Name  *string        `json:"name,omitempty"`
-	Nodes []TopologyNode `json:"nodes,omitempty"`
-	Edges []TopologyEdge `json:"edges,omitempty"`
+	Name     *string           `json:"name,omitempty"`
+	Nodes    []TopologyNode    `json:"nodes,omitempty"`
+	Edges    []TopologyEdge    `json:"edges,omitempty"`
+	Settings *TopologySettings `json:"settings,omitempty"` // replaces the stored settings
+}
+
+type ValidationIssue struct {
+	Severity string `json:"severity"` // error | warning
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	NodeID   string `json:"nodeId,omitempty"`
+	EdgeID   string `json:"edgeId,omitempty"`
+}
+
+type ValidationResult struct {
+	TopologyID string            `json:"topologyId,omitempty"`
+	Valid      bool              `json:"valid"`
+	Issues     []ValidationIssue `json:"issues"`
+}
+
+// Disconnected modes: how a deploy treats a topology made of several sub-graphs.
+const (
+	DisconnectedReject      = "reject"
+	DisconnectedIndependent = "independent"
+)
+
+type DeployOptions struct {
+	Namespace    string
+	Private      bool
+	Disconnected string            // reject | independent
+	Workload     kubetopo.Workload // overrides the topology settings
+	Defaults     kubetopo.Workload // server defaults under the topology settings
+	Timeout      time.Duration     // how long the deploy worker waits for the pods
+	// NetworkPolicies limits swarm traffic to edge neighbours; a deploy without
+	// it removes the policies of an earlier one.
+	NetworkPolicies bool
+	// APISources may reach the API and gateway ports under the policies.
+	APISources kubetopo.APISources
 }
 
 type DeployResult struct {
-	TopologyID string `json:"topologyId"`
-	Status     string `json:"status"`
-	Message    string `json:"message,omitempty"`
+	TopologyID   string `json:"topologyId"`
+	DeploymentID string `json:"deploymentId,omitempty"`
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"`
+	Networks     int    `json:"networks,omitempty"`
+	// Changes lists what the apply did to every Kubernetes object.
+	Changes []kubetopo.ObjectChange `json:"changes,omitempty"`
+}
+
+type PlannedPod struct {
+	NodeID   string `json:"nodeId"`
+	PodName  string `json:"podName"`
+	Workload string `json:"workload"`
+	Ordinal  int    `json:"ordinal"`
+}
+
+// DeployPlan is the dry-run result of a deploy: the objects that would be created
+// and the pod every canvas node would be assigned to.
+type DeployPlan struct {
+	TopologyID   string                   `json:"topologyId"`
+	Namespace    string                   `json:"namespace"`
+	ServerDryRun bool                     `json:"serverDryRun"`
+	Networks     int                      `json:"networks"`
+	Errors       int                      `json:"errors"`
+	Pods         []PlannedPod             `json:"pods"`
+	Objects      []kubetopo.PlannedObject `json:"objects"`
+}
+
+// Deployment is one deploy run: its status and step log.
+type Deployment struct {
+	DeploymentID string           `json:"deploymentId"`
+	TopologyID   string           `json:"topologyId"`
+	Namespace    string           `json:"namespace"`
+	Status       string           `json:"status"`
+	Message      string           `json:"message,omitempty"`
+	Steps        []DeploymentStep `json:"steps"`
+	CreatedAt    string           `json:"createdAt"`
+	UpdatedAt    string           `json:"updatedAt"`
+	FinishedAt   *string          `json:"finishedAt,omitempty"`
+}
+
+type DeploymentStep struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
 }
 
 type DeployStatus struct {
@@ -69,3 +230,208 @@ type PodStatus struct {
 	Phase   string `json:"phase"`
 	Ready   bool   `json:"ready"`
 }
+
+// PodStatusEvent is a pod change pushed by the status stream.
+type PodStatusEvent struct {
+	Type     string `json:"type"` // added | updated | deleted
+	NodeID   string `json:"nodeId"`
+	PodName  string `json:"podName"`
+	Phase    string `json:"phase"`
+	Ready    bool   `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// TopologyEvent is a Kubernetes event about a topology object. NodeID is set for
+// events of pods and PVCs.
+type TopologyEvent struct {
+	NodeID    string `json:"nodeId,omitempty"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	PodName   string `json:"podName,omitempty"`
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	Count     int32  `json:"count"`
+	FirstTime string `json:"firstTime"`
+	LastTime  string `json:"lastTime"`
+}
+
+// ClusterPeers is the ipfs-cluster view of a deployed topology as reported by
+// each of its peers.
+type ClusterPeers struct {
+	TopologyID string        `json:"topologyId"`
+	Consensus  string        `json:"consensus"` // crdt | raft
+	Formed     bool          `json:"formed"`    // every peer answered without errors and sees its whole network
+	Peers      []ClusterPeer `json:"peers"`
+}
+
+// ClusterPeer is one pod's ipfs-cluster identity. Peerset lists the canvas nodes
+// it sees (peer IDs that belong to no node are kept as is).
+type ClusterPeer struct {
+	NodeID       string   `json:"nodeId"`
+	PodName      string   `json:"podName"`
+	Reachable    bool     `json:"reachable"`
+	PeerID       string   `json:"peerId,omitempty"`
+	PeerName     string   `json:"peerName,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	Addresses    []string `json:"addresses,omitempty"`
+	IPFSPeerID   string   `json:"ipfsPeerId,omitempty"`
+	IPFSError    string   `json:"ipfsError,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	Peerset      []string `json:"peerset"`
+	MissingPeers []string `json:"missingPeers,omitempty"` // nodes of the same network absent from the peerset
+}
+
+// EdgeConformance compares the designed edges with the observed libp2p
+// connections between kubo nodes. Conformant means every designed edge is
+// connected; extra connections do not affect it.
+type EdgeConformance struct {
+	TopologyID string         `json:"topologyId"`
+	Conformant bool           `json:"conformant"`
+	Edges      []EdgeStatus   `json:"edges"`
+	Extra      []ObservedEdge `json:"extra"` // connections between nodes without a designed edge
+	Nodes      []NodeSwarm    `json:"nodes"`
+}
+
+type EdgeStatus struct {
+	EdgeID       string `json:"edgeId"`
+	SourceNodeID string `json:"sourceNodeId"`
+	TargetNodeID string `json:"targetNodeId"`
+	Status       string `json:"status"` // connected | missing | unknown
+}
+
+type ObservedEdge struct {
+	SourceNodeID string `json:"sourceNodeId"`
+	TargetNodeID string `json:"targetNodeId"`
+}
+
+// NodeSwarm is the swarm of one kubo node: all its connections and those to
+// peers outside the topology.
+type NodeSwarm struct {
+	NodeID        string `json:"nodeId"`
+	PodName       string `json:"podName"`
+	Reachable     bool   `json:"reachable"`
+	Error         string `json:"error,omitempty"`
+	Peers         int    `json:"peers"`
+	ExternalPeers int    `json:"externalPeers"`
+}
+
+// PinRequest pins a CID in the cluster. Replication factors of 0 keep the
+// cluster defaults, -1 pins on every peer.
+type PinRequest struct {
+	CID            string            `json:"cid"`
+	Name           string            `json:"name,omitempty"`
+	ReplicationMin int               `json:"replicationMin,omitempty"`
+	ReplicationMax int               `json:"replicationMax,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	NodeID         string            `json:"nodeId,omitempty"` // node whose cluster API receives the request
+}
+
+// Pin is a pin as stored by the cluster; Allocations are canvas node IDs.
+type Pin struct {
+	CID            string            `json:"cid"`
+	Name           string            `json:"name,omitempty"`
+	ReplicationMin int               `json:"replicationMin"`
+	ReplicationMax int               `json:"replicationMax"`
+	Allocations    []string          `json:"allocations"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+}
+
+// PinStatus is the state of a pin on every cluster peer.
+type PinStatus struct {
+	CID         string            `json:"cid"`
+	Name        string            `json:"name,omitempty"`
+	Allocations []string          `json:"allocations"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Peers       []PinPeerStatus   `json:"peers"`
+}
+
+type PinPeerStatus struct {
+	NodeID    string `json:"nodeId,omitempty"`
+	PeerID    string `json:"peerId"`
+	PeerName  string `json:"peerName,omitempty"`
+	Status    string `json:"status"` // pinned | pinning | pin_queued | pin_error | remote | ...
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// AddResult is the outcome of an upload: the root CID, the nodes it was
+// allocated to and every imported entry.
+type AddResult struct {
+	CID         string       `json:"cid"`
+	NodeID      string       `json:"nodeId"` // node the upload went through
+	PodName     string       `json:"podName"`
+	Allocations []string     `json:"allocations"`
+	Entries     []AddedEntry `json:"entries"`
+}
+
+type AddedEntry struct {
+	Name        string   `json:"name"`
+	CID         string   `json:"cid"`
+	Size        uint64   `json:"size"`
+	Allocations []string `json:"allocations,omitempty"`
+}
+
+// ContentHeatmap tells which nodes hold a CID. Holders counts nodes that have
+// the whole content (pinned or cached).
+type ContentHeatmap struct {
+	TopologyID    string        `json:"topologyId"`
+	CID           string        `json:"cid"`
+	ClusterPinned bool          `json:"clusterPinned"` // pinned on at least one cluster peer
+	ClusterError  string        `json:"clusterError,omitempty"`
+	Holders       int           `json:"holders"`
+	Nodes         []NodeHolding `json:"nodes"`
+}
+
+// NodeHolding is the holding state of a CID on one node.
+type NodeHolding struct {
+	NodeID        string `json:"nodeId"`
+	PodName       string `json:"podName"`
+	State         string `json:"state"` // pinned | pinning | cached | partial | absent | unknown
+	ClusterStatus string `json:"clusterStatus,omitempty"`
+	KuboPin       string `json:"kuboPin,omitempty"` // recursive | direct | indirect
+	RootPresent   bool   `json:"rootPresent"`
+	RootSize      int64  `json:"rootSize,omitempty"`
+	Complete      bool   `json:"complete"` // every block of the DAG is local
+	Blocks        int    `json:"blocks,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// GatewayResponse is a gateway answer of the node that served a content request.
+type GatewayResponse struct {
+	NodeID   string
+	PodName  string
+	TTFB     time.Duration
+	Response *http.Response
+}
+
+// PodLogs is an open log stream of a topology pod; the caller closes Stream.
+type PodLogs struct {
+	NodeID  string
+	PodName string
+	Stream  io.ReadCloser
+}
+
+// ChaosRequest starts a chaos experiment on a deployed node.
+type ChaosRequest struct {
+	Action          string `json:"action"` // kill | isolate | pause-cluster | fill-disk
+	DurationSeconds int    `json:"durationSeconds"`
+}
+
+// ChaosExperiment is a fault injected into the pod of a node, reverted when its
+// duration is over.
+type ChaosExperiment struct {
+	ExperimentID    string  `json:"experimentId"`
+	TopologyID      string  `json:"topologyId"`
+	NodeID          string  `json:"nodeId"`
+	Namespace       string  `json:"namespace"`
+	PodName         string  `json:"podName"`
+	Action          string  `json:"action"`
+	DurationSeconds int     `json:"durationSeconds"`
+	Status          string  `json:"status"` // running | reverted | failed
+	Message         string  `json:"message,omitempty"`
+	StartedAt       string  `json:"startedAt"`
+	EndsAt          string  `json:"endsAt"`
+	FinishedAt      *string `json:"finishedAt,omitempty"`
+}
@@ -0,0 +1,277 @@
+package topology
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Validation severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Validation issue codes.
+const (
+	IssueDanglingEdge      = "dangling_edge"
+	IssueSelfLoop          = "self_loop"
+	IssueDuplicateEdge     = "duplicate_edge"
+	IssueCycle             = "cycle"
+	IssueUnreachable       = "unreachable"
+	IssueNoBootstrap       = "no_bootstrap"
+	IssueDisconnected      = "disconnected"
+	IssueDuplicateNode     = "duplicate_node"
+	IssueConflictingRole   = "conflicting_role"
+	IssueUnknownRole       = "unknown_role"
+	IssueBootstrapOutgoing = "bootstrap_has_outgoing_edges"
+	IssueInvalidSettings   = "invalid_settings"
+	IssueInvalidOverrides  = "invalid_overrides"
+	IssueRandomSwarmPorts  = "random_swarm_ports"
+)
+
+// ValidateTopology runs the structural checks on a topology. Issues with error
+// severity make the topology undeployable and are rejected on create/update.
+func ValidateTopology(t *Topology) ValidationResult {
+	v := &validator{t: t}
+	v.checkNodes()
+	v.checkEdges()
+	v.checkCycles()
+	v.checkBootstrap()
+	v.checkSettings()
+
+	result := ValidationResult{TopologyID: t.TopologyID, Valid: true, Issues: v.issues}
+	if result.Issues == nil {
+		result.Issues = []ValidationIssue{}
+	}
+	for _, i := range result.Issues {
+		if i.Severity == SeverityError {
+			result.Valid = false
+			break
+		}
+	}
+	return result
+}
+
+type validator struct {
+	t      *Topology
+	issues []ValidationIssue
+	nodes  map[string]TopologyNode
+	edges  []TopologyEdge // edges between known nodes, no self-loops or duplicates
+}
+
+func (v *validator) add(severity, code, nodeID, edgeID, msg string) {
+	v.issues = append(v.issues, ValidationIssue{Severity: severity, Code: code, Message: msg, NodeID: nodeID, EdgeID: edgeID})
+}
+
+// checkSettings validates the images, sizes, resources, kubo and ipfs-cluster
+// config of the deploy settings. It runs after checkNodes.
+func (v *validator) checkSettings() {
+	if err := v.t.Settings.workload().Validate(); err != nil {
+		v.add(SeverityError, IssueInvalidSettings, "", "", err.Error())
+	}
+	if err := v.t.Settings.kubo().Validate(); err != nil {
+		v.add(SeverityError, IssueInvalidSettings, "", "", err.Error())
+	}
+	if v.t.Settings.kubo().HasProfile("randomports") {
+		v.add(SeverityWarning, IssueRandomSwarmPorts, "", "", randomPortsMessage)
+	}
+	cluster := v.t.Settings.cluster()
+	if err := cluster.Validate(); err != nil {
+		v.add(SeverityError, IssueInvalidSettings, "", "", err.Error())
+	}
+	for _, nodeID := range cluster.TrustedPeers {
+		if _, ok := v.nodes[nodeID]; !ok && nodeID != "*" {
+			v.add(SeverityError, IssueInvalidSettings, nodeID, "", fmt.Sprintf("trusted peer %s is not a node of the topology", nodeID))
+		}
+	}
+}
+
+// randomPortsMessage explains why the randomports profile breaks peering.
+const randomPortsMessage = "the randomports profile moves the kubo swarm off port 4001, which the Services and the peering of the edges use"
+
+func (v *validator) checkNodes() {
+	v.nodes = make(map[string]TopologyNode, len(v.t.Nodes))
+	for _, n := range v.t.Nodes {
+		switch n.Role {
+		case "", "worker", "bootstrap":
+		default:
+			v.add(SeverityError, IssueUnknownRole, n.NodeID, "", fmt.Sprintf("unknown role %q (expected bootstrap or worker)", n.Role))
+		}
+		if err := n.Overrides.overrides().Validate(); err != nil {
+			v.add(SeverityError, IssueInvalidOverrides, n.NodeID, "", err.Error())
+		}
+		if err := n.Overrides.kubo().Validate(); err != nil {
+			v.add(SeverityError, IssueInvalidOverrides, n.NodeID, "", err.Error())
+		}
+		if n.Overrides.kubo().HasProfile("randomports") {
+			v.add(SeverityWarning, IssueRandomSwarmPorts, n.NodeID, "", randomPortsMessage)
+		}
+		if n.NodeID == "" {
+			continue
+		}
+		prev, ok := v.nodes[n.NodeID]
+		if !ok {
+			v.nodes[n.NodeID] = n
+			continue
+		}
+		if roleOf(prev) != roleOf(n) {
+			v.add(SeverityError, IssueConflictingRole, n.NodeID, "", fmt.Sprintf("node is declared twice with roles %q and %q", roleOf(prev), roleOf(n)))
+		} else {
+			v.add(SeverityError, IssueDuplicateNode, n.NodeID, "", "node is declared more than once")
+		}
+	}
+}
+
+func (v *validator) checkEdges() {
+	seen := make(map[[2]string]string, len(v.t.Edges))
+	for _, e := range v.t.Edges {
+		_, srcOK := v.nodes[e.SourceNodeID]
+		_, dstOK := v.nodes[e.TargetNodeID]
+		switch {
+		case !srcOK:
+			v.add(SeverityError, IssueDanglingEdge, e.SourceNodeID, e.EdgeID, fmt.Sprintf("edge source %q does not exist", e.SourceNodeID))
+			continue
+		case !dstOK:
+			v.add(SeverityError, IssueDanglingEdge, e.TargetNodeID, e.EdgeID, fmt.Sprintf("edge target %q does not exist", e.TargetNodeID))
+			continue
+		case e.SourceNodeID == e.TargetNodeID:
+			v.add(SeverityError, IssueSelfLoop, e.SourceNodeID, e.EdgeID, "edge connects a node to itself")
+			continue
+		}
+		key := [2]string{e.SourceNodeID, e.TargetNodeID}
+		if first, ok := seen[key]; ok {
+			v.add(SeverityWarning, IssueDuplicateEdge, e.SourceNodeID, e.EdgeID, fmt.Sprintf("edge duplicates %s", first))
+			continue
+		}
+		seen[key] = e.EdgeID
+		v.edges = append(v.edges, e)
+
+		if roleOf(v.nodes[e.SourceNodeID]) == "bootstrap" {
+			v.add(SeverityWarning, IssueBootstrapOutgoing, e.SourceNodeID, e.EdgeID, "bootstrap node bootstraps to another node")
+		}
+	}
+}
+
+// checkCycles reports every edge that lies on a cycle of the bootstrap graph
+// (both ends in the same strongly connected component).
+func (v *validator) checkCycles() {
+	out := make(map[string][]string, len(v.nodes))
+	for _, e := range v.edges {
+		out[e.SourceNodeID] = append(out[e.SourceNodeID], e.TargetNodeID)
+	}
+	ids := make([]string, 0, len(v.nodes))
+	for id := range v.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Tarjan's strongly connected components.
+	index := make(map[string]int, len(ids))
+	low := make(map[string]int, len(ids))
+	onStack := make(map[string]bool, len(ids))
+	comp := make(map[string]int, len(ids))
+	var stack []string
+	next, compID := 0, 0
+	var strongConnect func(string)
+	strongConnect = func(id string) {
+		index[id], low[id] = next, next
+		next++
+		stack = append(stack, id)
+		onStack[id] = true
+		for _, w := range out[id] {
+			if _, visited := index[w]; !visited {
+				strongConnect(w)
+				low[id] = min(low[id], low[w])
+			} else if onStack[w] {
+				low[id] = min(low[id], index[w])
+			}
+		}
+		if low[id] == index[id] {
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				comp[w] = compID
+				if w == id {
+					break
+				}
+			}
+			compID++
+		}
+	}
+	for _, id := range ids {
+		if _, visited := index[id]; !visited {
+			strongConnect(id)
+		}
+	}
+
+	for _, e := range v.edges {
+		if comp[e.SourceNodeID] == comp[e.TargetNodeID] {
+			v.add(SeverityWarning, IssueCycle, e.SourceNodeID, e.EdgeID, "edge is part of a bootstrap cycle")
+		}
+	}
+}
+
+// checkBootstrap reports components without a bootstrap node, nodes that cannot reach
+// a bootstrap node by following their outgoing edges and disconnected components.
+func (v *validator) checkBootstrap() {
+	valid := &Topology{Edges: v.edges}
+	added := make(map[string]bool, len(v.nodes))
+	for _, n := range v.t.Nodes {
+		if n.NodeID == "" || added[n.NodeID] {
+			continue
+		}
+		added[n.NodeID] = true
+		valid.Nodes = append(valid.Nodes, n)
+	}
+	if len(valid.Nodes) == 0 {
+		return
+	}
+
+	in := make(map[string][]string, len(valid.Nodes))
+	for _, e := range v.edges {
+		in[e.TargetNodeID] = append(in[e.TargetNodeID], e.SourceNodeID)
+	}
+
+	comps := components(valid)
+	for i, c := range comps {
+		if len(comps) > 1 {
+			v.add(SeverityWarning, IssueDisconnected, c[0], "",
+				fmt.Sprintf("component %d of %d (%d nodes) is not connected to the rest; deploy it with disconnected=independent", i+1, len(comps), len(c)))
+		}
+		roots := resolveBootstrapNodes(valid, c)
+		if len(roots) == 0 {
+			for _, id := range c {
+				v.add(SeverityError, IssueNoBootstrap, id, "", "node's component has no bootstrap node")
+			}
+			continue
+		}
+		reached := make(map[string]bool, len(c))
+		queue := append([]string(nil), roots...)
+		for _, r := range roots {
+			reached[r] = true
+		}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, src := range in[id] {
+				if !reached[src] {
+					reached[src] = true
+					queue = append(queue, src)
+				}
+			}
+		}
+		for _, id := range c {
+			if !reached[id] {
+				v.add(SeverityWarning, IssueUnreachable, id, "", "node cannot reach a bootstrap node through its outgoing edges")
+			}
+		}
+	}
+}
+
+func roleOf(n TopologyNode) string {
+	if n.Role == "" {
+		return "worker"
+	}
+	return n.Role
+}
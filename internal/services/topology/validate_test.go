@@ -0,0 +1,165 @@
+package topology
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func testTopology(nodes []TopologyNode, edges ...[3]string) *Topology {
+	t := &Topology{Nodes: nodes}
+	for _, e := range edges {
+		t.Edges = append(t.Edges, TopologyEdge{EdgeID: e[0], SourceNodeID: e[1], TargetNodeID: e[2]})
+	}
+	return t
+}
+
+func workers(ids ...string) []TopologyNode {
+	nodes := make([]TopologyNode, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, TopologyNode{NodeID: id})
+	}
+	return nodes
+}
+
+func bootstrap(id string) TopologyNode {
+	return TopologyNode{NodeID: id, Role: "bootstrap"}
+}
+
+// issueKeys lists the issues as "severity code node edge", sorted.
+func issueKeys(issues []ValidationIssue) []string {
+	keys := make([]string, 0, len(issues))
+	for _, i := range issues {
+		keys = append(keys, i.Severity+" "+i.Code+" "+i.NodeID+" "+i.EdgeID)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestValidateTopology(t *testing.T) {
+	tests := []struct {
+		name   string
+		t      *Topology
+		valid  bool
+		issues []string
+	}{
+		{
+			name:   "chain to a bootstrap node",
+			t:      testTopology(append(workers("b", "c"), bootstrap("a")), [3]string{"e1", "b", "a"}, [3]string{"e2", "c", "b"}),
+			valid:  true,
+			issues: []string{},
+		},
+		{
+			name:   "dangling edge",
+			t:      testTopology([]TopologyNode{bootstrap("a")}, [3]string{"e1", "a", "x"}),
+			issues: []string{"error dangling_edge x e1"},
+		},
+		{
+			name:   "self-loop",
+			t:      testTopology([]TopologyNode{bootstrap("a")}, [3]string{"e1", "a", "a"}),
+			issues: []string{"error self_loop a e1"},
+		},
+		{
+			name:   "duplicate edge",
+			t:      testTopology(append(workers("b"), bootstrap("a")), [3]string{"e1", "b", "a"}, [3]string{"e2", "b", "a"}),
+			valid:  true,
+			issues: []string{"warning duplicate_edge b e2"},
+		},
+		{
+			name:   "unknown role",
+			t:      testTopology([]TopologyNode{{NodeID: "a", Role: "leader"}}),
+			issues: []string{"error unknown_role a "},
+		},
+		{
+			name:   "node declared twice",
+			t:      testTopology(append(workers("a", "a"), bootstrap("b"), TopologyNode{NodeID: "b"}), [3]string{"e1", "a", "b"}),
+			issues: []string{"error conflicting_role b ", "error duplicate_node a "},
+		},
+		{
+			name: "component without a bootstrap node",
+			t:    testTopology(workers("a", "b"), [3]string{"e1", "a", "b"}, [3]string{"e2", "b", "a"}),
+			issues: []string{
+				"error no_bootstrap a ", "error no_bootstrap b ",
+				"warning cycle a e1", "warning cycle b e2",
+			},
+		},
+		{
+			name:   "node behind a bootstrap node cannot reach it",
+			t:      testTopology(append(workers("b"), bootstrap("a")), [3]string{"e1", "a", "b"}),
+			valid:  true,
+			issues: []string{"warning bootstrap_has_outgoing_edges a e1", "warning unreachable b "},
+		},
+		{
+			name:   "disconnected components",
+			t:      testTopology([]TopologyNode{bootstrap("a"), bootstrap("b")}),
+			valid:  true,
+			issues: []string{"warning disconnected a ", "warning disconnected b "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateTopology(tt.t)
+			if got.Valid != tt.valid {
+				t.Errorf("Valid = %v, want %v (issues %v)", got.Valid, tt.valid, got.Issues)
+			}
+			if keys := issueKeys(got.Issues); !reflect.DeepEqual(keys, tt.issues) {
+				t.Errorf("issues = %q, want %q", keys, tt.issues)
+			}
+		})
+	}
+}
+
+// TestValidateTopologyCycles checks that exactly the edges inside a strongly
+// connected component are reported, not the edges between components.
+func TestValidateTopologyCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		t      *Topology
+		cycles []string
+	}{
+		{
+			name:   "acyclic",
+			t:      testTopology(append(workers("b", "c"), bootstrap("a")), [3]string{"e1", "b", "a"}, [3]string{"e2", "c", "a"}, [3]string{"e3", "c", "b"}),
+			cycles: []string{},
+		},
+		{
+			name: "two-node cycle leading to a bootstrap node",
+			t: testTopology(append(workers("b", "c"), bootstrap("a")),
+				[3]string{"e1", "b", "c"}, [3]string{"e2", "c", "b"}, [3]string{"e3", "c", "a"}),
+			cycles: []string{"e1", "e2"},
+		},
+		{
+			name: "three-node cycle",
+			t: testTopology(append(workers("b", "c", "d"), bootstrap("a")),
+				[3]string{"e1", "b", "c"}, [3]string{"e2", "c", "d"}, [3]string{"e3", "d", "b"}, [3]string{"e4", "d", "a"}),
+			cycles: []string{"e1", "e2", "e3"},
+		},
+		{
+			name: "two cycles joined by an edge",
+			t: testTopology(append(workers("a", "b", "d"), bootstrap("c")),
+				[3]string{"e1", "a", "b"}, [3]string{"e2", "b", "a"}, [3]string{"e3", "b", "c"},
+				[3]string{"e4", "c", "d"}, [3]string{"e5", "d", "c"}),
+			cycles: []string{"e1", "e2", "e4", "e5"},
+		},
+		{
+			name: "self-loops and duplicates are not cycles",
+			t: testTopology(append(workers("b"), bootstrap("a")),
+				[3]string{"e1", "b", "b"}, [3]string{"e2", "b", "a"}, [3]string{"e3", "b", "a"}),
+			cycles: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cycles := []string{}
+			for _, i := range ValidateTopology(tt.t).Issues {
+				if i.Code == IssueCycle {
+					cycles = append(cycles, i.EdgeID)
+				}
+			}
+			sort.Strings(cycles)
+			if !reflect.DeepEqual(cycles, tt.cycles) {
+				t.Errorf("cycle edges = %v, want %v", cycles, tt.cycles)
+			}
+		})
+	}
+}